@@ -0,0 +1,348 @@
+package buckets
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+
+	"github.com/boltdb/bolt"
+)
+
+// Backup writes a consistent snapshot of the database to `w`, returning
+// the number of bytes written.  The snapshot is taken in a read-only
+// transaction, so writers are not blocked while it is written.
+func (db *DB) Backup(w io.Writer) (n int64, err error) {
+	err = db.View(func(tx *bolt.Tx) error {
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return n, err
+}
+
+// BackupTo writes a snapshot of the database to the file at `path`,
+// as in Backup.  The snapshot is written to a temporary file first,
+// which then replaces any existing file at `path`, so the file is
+// never left partially written.  `path` can't be the database's own
+// file, since writes made after it was replaced would be lost.
+func (db *DB) BackupTo(path string) error {
+	if sameFile(path, db.Path()) {
+		return fmt.Errorf("can't back up %s onto itself", path)
+	}
+	return writeFile(path, 0600, func(w io.Writer) error {
+		_, err := db.Backup(w)
+		return err
+	})
+}
+
+// BackupHandler returns an http.Handler that responds to GET requests
+// with a snapshot of the database, as an attachment to download.
+func (db *DB) BackupHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var n int64
+		err := db.View(func(tx *bolt.Tx) (err error) {
+			name := strconv.Quote(filepath.Base(db.Path()))
+			h := w.Header()
+			h.Set("Content-Type", "application/octet-stream")
+			h.Set("Content-Disposition", "attachment; filename="+name)
+			h.Set("Content-Length", strconv.FormatInt(tx.Size(), 10))
+			if r.Method == http.MethodHead {
+				return nil
+			}
+			n, err = tx.WriteTo(w)
+			return err
+		})
+		switch {
+		case err == nil:
+		case n == 0:
+			w.Header().Del("Content-Disposition")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		default:
+			// Too late to send an error status, so cut the response short.
+			panic(http.ErrAbortHandler)
+		}
+	})
+}
+
+// compactTxSize is the number of bytes CompactTo copies per transaction.
+const compactTxSize = 16 << 20
+
+// CompactTo writes a compacted copy of the database to the file at
+// `path`: one with every bucket and item (and bucket sequence), but
+// none of the free pages left by deletes and updates, so it is usually
+// smaller.  As with BackupTo, the copy is written to a temporary file
+// first, which then replaces any existing file at `path`.
+//
+// `path` can't be the database's own file, since writes made after it
+// was replaced would be lost; use Compact to compact in place.
+func (db *DB) CompactTo(path string) error {
+	if sameFile(path, db.Path()) {
+		return fmt.Errorf("can't compact %s onto itself (use Compact)", path)
+	}
+	tmp, err := db.compact(path)
+	if err != nil {
+		return err
+	}
+	if err := replace(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// Compact compacts the database in place: it writes a compacted copy,
+// as with CompactTo, which replaces the database file while the file is
+// still locked, and then reopens the database with the options it was
+// opened with.  The database must be open read-write, and must not be
+// used by other goroutines until Compact returns.  If it can't be
+// reopened, the database is left closed.
+func (db *DB) Compact() error {
+	if db.IsReadOnly() {
+		return fmt.Errorf("can't compact %s: opened read-only", db.Path())
+	}
+	path := db.Path()
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := db.compact(path)
+	if err == nil {
+		err = os.Chmod(tmp, info.Mode().Perm())
+	}
+	if err == nil {
+		err = replace(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := db.DB.Close(); err != nil {
+		return err
+	}
+	bdb, err := openBolt(path, db.options)
+	if err != nil {
+		return err
+	}
+	db.DB = bdb
+	return nil
+}
+
+// compact writes a compacted copy of the database to a new temporary
+// file in the directory of `path`, returning the file's name.
+func (db *DB) compact(path string) (tmp string, err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-")
+	if err != nil {
+		return "", err
+	}
+	tmp = f.Name()
+	f.Close()
+	defer func() {
+		if err != nil {
+			os.Remove(tmp)
+		}
+	}()
+
+	dst, err := bolt.Open(tmp, 0600, &bolt.Options{
+		Timeout: DefaultOptions.Timeout,
+	})
+	if err != nil {
+		return "", err
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		c := &copier{db: dst}
+		return c.finish(tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return c.copy([][]byte{name}, b)
+		}))
+	})
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	return tmp, nil
+}
+
+// sameFile checks whether paths `a` and `b` name the same existing file.
+func sameFile(a, b string) bool {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	return err == nil && os.SameFile(fa, fb)
+}
+
+// A copier copies buckets into a database, committing a transaction
+// every compactTxSize bytes.
+type copier struct {
+	db   *bolt.DB
+	tx   *bolt.Tx
+	size int
+}
+
+// copy copies bucket `b` and its nested buckets to `path`.
+func (c *copier) copy(path [][]byte, b *bolt.Bucket) error {
+	dst, err := c.bucket(path)
+	if err != nil {
+		return err
+	}
+	if err := dst.SetSequence(b.Sequence()); err != nil {
+		return err
+	}
+	return b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			child := append(append([][]byte{}, path...), k)
+			return c.copy(child, b.Bucket(k))
+		}
+		if c.size > compactTxSize {
+			if err := c.tx.Commit(); err != nil {
+				return err
+			}
+			c.tx, c.size = nil, 0
+		}
+		dst, err := c.bucket(path)
+		if err != nil {
+			return err
+		}
+		c.size += len(k) + len(v)
+		return dst.Put(k, v)
+	})
+}
+
+// bucket returns the bucket at `path` within the current transaction,
+// beginning one if needed, and creating the bucket if needed.
+func (c *copier) bucket(path [][]byte) (*bolt.Bucket, error) {
+	if c.tx == nil {
+		tx, err := c.db.Begin(true)
+		if err != nil {
+			return nil, err
+		}
+		c.tx = tx
+	}
+	b, err := c.tx.CreateBucketIfNotExists(path[0])
+	for _, name := range path[1:] {
+		if err != nil {
+			break
+		}
+		b, err = b.CreateBucketIfNotExists(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.FillPercent = 1 // keys are copied in order, so fill each page
+	return b, nil
+}
+
+// finish commits the current transaction, or rolls it back if `err`
+// is non-nil, returning `err`.
+func (c *copier) finish(err error) error {
+	if c.tx == nil {
+		return err
+	}
+	if err != nil {
+		c.tx.Rollback()
+		return err
+	}
+	return c.tx.Commit()
+}
+
+// Restore replaces the database file at `dst` with the backup at `src`
+// (e.g., one written by BackupTo).  The database at `dst` must not be
+// open: Restore fails if it cannot lock the file in time.  Like BackupTo,
+// it replaces the file atomically, keeping the file's mode.
+func Restore(src, dst string) error {
+	// Make sure the backup is a database we can open.
+	backup, err := bolt.Open(src, 0600, &bolt.Options{
+		ReadOnly: true,
+		Timeout:  DefaultOptions.Timeout,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't open backup %s: %w", src, err)
+	}
+	backup.Close()
+
+	// Hold the lock on the current database until it's replaced.  If
+	// it can't be opened at all (e.g., it's corrupted), replace it
+	// anyway.
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(dst); err == nil {
+		mode = info.Mode().Perm()
+		cur, err := bolt.Open(dst, 0600, &bolt.Options{
+			Timeout: DefaultOptions.Timeout,
+		})
+		if errors.Is(err, ErrTimeout) {
+			return fmt.Errorf("couldn't lock %s: %w", dst, err)
+		}
+		if err == nil {
+			defer cur.Close()
+		}
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return writeFile(dst, mode, func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	})
+}
+
+// writeFile writes the file at `path` with `write`, by way of a
+// temporary file in the same directory that is synced and then renamed
+// (see replace).
+func writeFile(path string, mode os.FileMode,
+	write func(w io.Writer) error) (err error) {
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	if err = write(f); err != nil {
+		return err
+	}
+	if err = f.Chmod(mode); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return replace(f.Name(), path)
+}
+
+// replace renames file `tmp` to `path`, and then syncs the directory,
+// so that the rename survives a crash.
+func replace(tmp, path string) error {
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	if err := dir.Sync(); err != nil && runtime.GOOS != "windows" {
+		return err // directories can't be synced on windows
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package buckets_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// get returns the value of key `k` in the named bucket of `bx`.
+func get(t *testing.T, bx *buckets.DB, name, k string) string {
+	t.Helper()
+	bk, err := bx.Bucket([]byte(name))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	v, err := bk.Get([]byte(k))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return string(v)
+}
+
+// Ensure we can back up a database and restore it from the backup.
+func TestBackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "live.db")
+	backup := filepath.Join(dir, "backup.db")
+
+	bx, err := buckets.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	things, _ := bx.New([]byte("things"))
+	things.Put([]byte("a"), []byte("before"))
+
+	if err := bx.BackupTo(backup); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := bx.BackupTo(path); err == nil {
+		t.Error("expected error backing up onto the database itself")
+	}
+	things.Put([]byte("a"), []byte("after"))
+
+	// The database must be closed to restore it.
+	if err := buckets.Restore(backup, path); err == nil {
+		t.Error("expected error restoring open database")
+	}
+	bx.Close()
+
+	// A file that isn't a database can't be restored.
+	junk := filepath.Join(dir, "junk")
+	os.WriteFile(junk, []byte("junk"), 0600)
+	if err := buckets.Restore(junk, path); err == nil {
+		t.Error("expected error restoring from junk")
+	}
+	os.Remove(junk)
+
+	// The restored file keeps the mode of the file it replaces.
+	os.Chmod(path, 0640)
+	if err := buckets.Restore(backup, path); err != nil {
+		t.Fatal(err.Error())
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+		t.Errorf("got mode %v after restore, want 0640", info.Mode())
+	}
+	bx, err = buckets.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer bx.Close()
+	if got := get(t, bx, "things", "a"); got != "before" {
+		t.Errorf("got %s, want before", got)
+	}
+
+	// No temporary files are left behind.
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 2 {
+		t.Errorf("got files %v, want just the database and backup", files)
+	}
+}
+
+// Ensure that backups written to a writer can be opened.
+func TestBackup(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+	things.Put([]byte("a"), []byte("alpha"))
+
+	var buf bytes.Buffer
+	n, err := bx.Backup(&buf)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("got %d bytes written, want %d", n, buf.Len())
+	}
+
+	path := filepath.Join(t.TempDir(), "copy.db")
+	os.WriteFile(path, buf.Bytes(), 0600)
+	copied, err := buckets.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer copied.Close()
+	if got := get(t, copied, "things", "a"); got != "alpha" {
+		t.Errorf("got %s, want alpha", got)
+	}
+}
+
+// Ensure that backups can be downloaded over http.
+func TestBackupHandler(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+	things.Put([]byte("a"), []byte("alpha"))
+
+	srv := httptest.NewServer(bx.BackupHandler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %s, want 200 OK", resp.Status)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/octet-stream" {
+		t.Errorf("got content type %s", ct)
+	}
+
+	path := filepath.Join(t.TempDir(), "download.db")
+	f, _ := os.Create(path)
+	io.Copy(f, resp.Body)
+	f.Close()
+
+	downloaded, err := buckets.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer downloaded.Close()
+	if got := get(t, downloaded, "things", "a"); got != "alpha" {
+		t.Errorf("got %s, want alpha", got)
+	}
+
+	resp, err = http.Post(srv.URL, "text/plain", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("got status %s, want 405", resp.Status)
+	}
+}
+
+// Ensure that a compacted copy of a database has everything, in less
+// space.
+func TestCompactTo(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+	for i := 0; i < 1000; i++ {
+		things.Put([]byte(fmt.Sprintf("%04d", i)), bytes.Repeat([]byte("x"), 100))
+	}
+	for i := 0; i < 900; i++ {
+		things.Delete([]byte(fmt.Sprintf("%04d", i)))
+	}
+	nested, _ := things.New([]byte("nested"))
+	nested.NextID()
+	nested.Put([]byte("a"), []byte("alpha"))
+
+	path := filepath.Join(t.TempDir(), "compact.db")
+	if err := bx.CompactTo(path); err != nil {
+		t.Fatal(err.Error())
+	}
+	before, _ := os.Stat(bx.Path())
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size() {
+		t.Errorf("got size %d, want less than %d", after.Size(), before.Size())
+	}
+
+	compacted, err := buckets.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer compacted.Close()
+	copied, err := compacted.BucketPath([]byte("things"), []byte("nested"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if v, _ := copied.Get([]byte("a")); string(v) != "alpha" {
+		t.Errorf("got %s, want alpha", v)
+	}
+	if id, _ := copied.NextID(); id != 2 {
+		t.Errorf("got next id %d, want 2 (sequence not copied)", id)
+	}
+	if got := get(t, compacted, "things", "0999"); len(got) != 100 {
+		t.Errorf("got value of length %d, want 100", len(got))
+	}
+
+	// A database can't be compacted onto its own file.
+	if err := bx.CompactTo(bx.Path()); err == nil {
+		t.Error("expected error compacting onto the database itself")
+	}
+}
+
+// Ensure that a database can be compacted in place, and used
+// afterwards.
+func TestCompact(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "live.db")
+	bx, err := buckets.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	things, _ := bx.New([]byte("things"))
+	for i := 0; i < 1000; i++ {
+		things.Put([]byte(fmt.Sprintf("%04d", i)), bytes.Repeat([]byte("x"), 100))
+	}
+	for i := 1; i < 1000; i++ {
+		things.Delete([]byte(fmt.Sprintf("%04d", i)))
+	}
+	os.Chmod(path, 0640)
+	before, _ := os.Stat(path)
+
+	if err := bx.Compact(); err != nil {
+		t.Fatal(err.Error())
+	}
+	after, _ := os.Stat(path)
+	if after.Size() >= before.Size() {
+		t.Errorf("got size %d, want less than %d", after.Size(), before.Size())
+	}
+	if after.Mode().Perm() != 0640 {
+		t.Errorf("got mode %v, want 0640", after.Mode())
+	}
+
+	// Writes made after compacting are kept.
+	things.Put([]byte("a"), []byte("alpha"))
+	bx.Close()
+	bx, err = buckets.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer bx.Close()
+	if got := get(t, bx, "things", "a"); got != "alpha" {
+		t.Errorf("got %s, want alpha", got)
+	}
+	if got := get(t, bx, "things", "0000"); len(got) != 100 {
+		t.Errorf("got value of length %d, want 100", len(got))
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 {
+		t.Errorf("got files %v, want just the database", files)
+	}
+}
@@ -2,8 +2,11 @@ package buckets_test
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"sync"
 	"testing"
 
 	"github.com/joyrexus/buckets"
@@ -48,6 +51,39 @@ func TestPut(t *testing.T) {
 	}
 }
 
+// Ensure that putting a nil value stores an empty value rather than
+// deleting the key.
+func TestPutNil(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	key := []byte("A")
+	if err := things.Put(key, []byte("alpha")); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := things.Put(key, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+	got, err := things.Get(key)
+	if err != nil {
+		t.Fatalf("got error %v, want empty value", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("got %q, want empty value", got)
+	}
+
+	if err := things.Insert([]struct{ Key, Value []byte }{{[]byte("B"), nil}}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := things.Get([]byte("B")); err != nil {
+		t.Errorf("got error %v for inserted nil value, want empty value", err)
+	}
+}
+
 // Show we can put an item in a bucket and get it back out.
 func ExampleBucket_Put() {
 	bx, _ := buckets.Open(tempfile())
@@ -143,7 +179,8 @@ func ExampleBucket_PutNX() {
 	// The value of "A" in `things` is still "alpha"
 }
 
-// Ensure that a bucket that gets a non-existent key returns nil.
+// Ensure that a bucket that gets a non-existent key returns nil
+// and ErrKeyNotFound.
 func TestGetMissing(t *testing.T) {
 	bx := NewTestDB()
 	defer bx.Close()
@@ -154,9 +191,13 @@ func TestGetMissing(t *testing.T) {
 	}
 
 	key := []byte("missing")
-	if got, _ := things.Get(key); got != nil {
+	got, err := things.Get(key)
+	if got != nil {
 		t.Errorf("not expecting value for key %q: got %q", key, got)
 	}
+	if !errors.Is(err, buckets.ErrKeyNotFound) {
+		t.Errorf("got error %v, want %v", err, buckets.ErrKeyNotFound)
+	}
 }
 
 // Ensure that we can delete stuff in a bucket.
@@ -520,3 +561,269 @@ func ExampleBucket_RangeItems() {
 	// 1995 -> 95
 	// 2000 -> 00
 }
+
+// Ensure that concurrent PutNX calls for the same key store one value.
+func TestPutNXConcurrent(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	key := []byte("A")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := things.PutNX(key, []byte{byte(i)}); err != nil {
+				t.Error(err.Error())
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	got, err := things.Get(key)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(got) != 1 {
+		t.Errorf("got %v, want a single winning value", got)
+	}
+}
+
+// Ensure we only update existing items when using PutXX.
+func TestPutXX(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	key := []byte("A")
+	a, b := []byte("alpha"), []byte("beta")
+
+	ok, err := things.PutXX(key, a)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if ok {
+		t.Error("PutXX should not put a missing key")
+	}
+	if _, err := things.Get(key); !errors.Is(err, buckets.ErrKeyNotFound) {
+		t.Errorf("got error %v, want %v", err, buckets.ErrKeyNotFound)
+	}
+
+	things.Put(key, a)
+	if ok, err = things.PutXX(key, b); err != nil || !ok {
+		t.Errorf("PutXX should update an existing key: %v, %v", ok, err)
+	}
+	if got, _ := things.Get(key); !bytes.Equal(got, b) {
+		t.Errorf("got %s, want %s", got, b)
+	}
+}
+
+// Ensure that CompareAndSwap only swaps expected values.
+func TestCompareAndSwap(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	key := []byte("A")
+	a, b := []byte("alpha"), []byte("beta")
+
+	// A nil `old` value matches a missing key.
+	if ok, err := things.CompareAndSwap(key, nil, a); err != nil || !ok {
+		t.Errorf("expected swap for missing key: %v, %v", ok, err)
+	}
+	if ok, err := things.CompareAndSwap(key, nil, b); err != nil || ok {
+		t.Errorf("not expecting swap for existing key: %v, %v", ok, err)
+	}
+	if ok, err := things.CompareAndSwap(key, b, b); err != nil || ok {
+		t.Errorf("not expecting swap for wrong value: %v, %v", ok, err)
+	}
+	if ok, err := things.CompareAndSwap(key, a, b); err != nil || !ok {
+		t.Errorf("expected swap for matching value: %v, %v", ok, err)
+	}
+	if got, _ := things.Get(key); !bytes.Equal(got, b) {
+		t.Errorf("got %s, want %s", got, b)
+	}
+}
+
+// Ensure that concurrent CompareAndSwap loops don't lose updates.
+func TestCompareAndSwapConcurrent(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	counts, err := bx.New([]byte("counts"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	key := []byte("hits")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				old, err := counts.Get(key)
+				if err != nil && !errors.Is(err, buckets.ErrKeyNotFound) {
+					t.Error(err.Error())
+					return
+				}
+				new := []byte(strconv.Itoa(atoi(old) + 1))
+				ok, err := counts.CompareAndSwap(key, old, new)
+				if err != nil {
+					t.Error(err.Error())
+					return
+				}
+				if ok {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, _ := counts.Get(key)
+	if string(got) != "10" {
+		t.Errorf("got %s, want 10", got)
+	}
+}
+
+// atoi converts a byte-sliced number to an int.  Nil is converted to 0.
+func atoi(b []byte) int {
+	n, _ := strconv.Atoi(string(b))
+	return n
+}
+
+// Ensure that DeleteIf only deletes expected values.
+func TestDeleteIf(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	key := []byte("A")
+	a, b := []byte("alpha"), []byte("beta")
+	things.Put(key, a)
+
+	if ok, err := things.DeleteIf(key, b); err != nil || ok {
+		t.Errorf("not expecting delete for wrong value: %v, %v", ok, err)
+	}
+	if got, _ := things.Get(key); !bytes.Equal(got, a) {
+		t.Errorf("got %s, want %s", got, a)
+	}
+	if ok, err := things.DeleteIf(key, a); err != nil || !ok {
+		t.Errorf("expected delete for matching value: %v, %v", ok, err)
+	}
+	if _, err := things.Get(key); !errors.Is(err, buckets.ErrKeyNotFound) {
+		t.Errorf("got error %v, want %v", err, buckets.ErrKeyNotFound)
+	}
+}
+
+// Ensure we can update an item in a single transaction.
+func TestUpdate(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	counts, err := bx.New([]byte("counts"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	key := []byte("hits")
+	incr := func(old []byte) ([]byte, error) {
+		return []byte(strconv.Itoa(atoi(old) + 1)), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := counts.Update(key, incr); err != nil {
+				t.Error(err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got, _ := counts.Get(key); string(got) != "10" {
+		t.Errorf("got %s, want 10", got)
+	}
+
+	// Returning an error leaves the value untouched.
+	oops := errors.New("oops")
+	err = counts.Update(key, func(old []byte) ([]byte, error) {
+		return []byte("0"), oops
+	})
+	if err != oops {
+		t.Errorf("got error %v, want %v", err, oops)
+	}
+	if got, _ := counts.Get(key); string(got) != "10" {
+		t.Errorf("got %s, want 10", got)
+	}
+
+	// Returning nil deletes the key.
+	err = counts.Update(key, func(old []byte) ([]byte, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := counts.Get(key); !errors.Is(err, buckets.ErrKeyNotFound) {
+		t.Errorf("got error %v, want %v", err, buckets.ErrKeyNotFound)
+	}
+}
+
+// Ensure we can update several items in a single transaction.
+func TestUpdateKeys(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	counts, err := bx.New([]byte("counts"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	counts.Put([]byte("a"), []byte("1"))
+	counts.Put([]byte("b"), []byte("2"))
+
+	keys := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	double := func(k, old []byte) ([]byte, error) {
+		if old == nil {
+			return nil, fmt.Errorf("missing key %q", k)
+		}
+		return []byte(strconv.Itoa(atoi(old) * 2)), nil
+	}
+
+	// The missing key aborts the whole batch.
+	if err := counts.UpdateKeys(keys, double); err == nil {
+		t.Error("expected error for missing key")
+	}
+	if got, _ := counts.Get([]byte("a")); string(got) != "1" {
+		t.Errorf("got %s, want 1", got)
+	}
+
+	if err := counts.UpdateKeys(keys[:2], double); err != nil {
+		t.Error(err.Error())
+	}
+	items, _ := counts.Items()
+	for i, want := range []string{"2", "4"} {
+		if got := string(items[i].Value); got != want {
+			t.Errorf("%s: got %s, want %s", items[i].Key, got, want)
+		}
+	}
+}
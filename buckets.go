@@ -3,6 +3,7 @@ package buckets
 import (
 	"bytes"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/boltdb/bolt"
@@ -13,22 +14,32 @@ import (
 // A DB embeds the exposed bolt.DB methods.
 type DB struct {
 	*bolt.DB
+	clock   func() time.Time
+	options *Options // opened with, for reopening
+
+	mu       sync.Mutex         // guards indexes and watchers
+	indexes  map[string][]index // registered indexes by bucket pathKey
+	watchers map[*Watcher]bool
 }
 
-// Open creates/opens a buckets database at the specified path.
-func Open(path string) (*DB, error) {
-	config := &bolt.Options{Timeout: 1 * time.Second}
-	db, err := bolt.Open(path, 0600, config)
-	if err != nil {
-		return nil, fmt.Errorf("couldn't open %s: %s", path, err)
+// now returns the current time according to the database's clock.
+func (db *DB) now() time.Time {
+	if db.clock == nil {
+		return time.Now()
 	}
-	return &DB{db}, nil
+	return db.clock()
+}
+
+// Open creates/opens a buckets database at the specified path,
+// using DefaultOptions.
+func Open(path string) (*DB, error) {
+	return OpenWithOptions(path, nil)
 }
 
 // New creates/opens a named bucket.
 func (db *DB) New(name []byte) (*Bucket, error) {
 	err := db.Update(func(tx *bolt.Tx) error {
-		_, err := tx.CreateBucketIfNotExists(name)
+		_, err := db.createBucket(tx, tx, [][]byte{name})
 		if err != nil {
 			return err
 		}
@@ -37,13 +48,46 @@ func (db *DB) New(name []byte) (*Bucket, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Bucket{db, name}, nil
+	return &Bucket{db: db, Name: name}, nil
+}
+
+// NewPath creates/opens a nested bucket, creating each bucket along the
+// path as needed.  The first name in the path identifies a top-level bucket,
+// each following name a bucket nested within the previous one.
+func (db *DB) NewPath(path ...[]byte) (*Bucket, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("empty bucket path")
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		var parent bucketCreator = tx
+		for i := range path {
+			b, err := db.createBucket(tx, parent, path[:i+1])
+			if err != nil {
+				return err
+			}
+			parent = b
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db.bucketAt(path), nil
+}
+
+// bucketAt returns a handle for the bucket at `path`.
+func (db *DB) bucketAt(path [][]byte) *Bucket {
+	var bk *Bucket
+	for _, name := range path {
+		bk = &Bucket{db: db, parent: bk, Name: name}
+	}
+	return bk
 }
 
 // Delete removes the named bucket.
 func (db *DB) Delete(name []byte) error {
 	return db.Update(func(tx *bolt.Tx) error {
-		return tx.DeleteBucket(name)
+		return db.deleteBucket(tx, tx, [][]byte{name})
 	})
 }
 
@@ -58,38 +102,241 @@ type Item struct {
 /* -- BUCKET-- */
 
 // Bucket represents a collection of key/value pairs inside the database.
+// A bucket may also contain other (nested) buckets.
 type Bucket struct {
-	db   *DB
-	Name []byte
+	db     *DB
+	tx     *bolt.Tx // non-nil for handles bound to a transaction
+	parent *Bucket  // nil for top-level buckets
+	Name   []byte
+}
+
+// bucket returns the bolt bucket for bk within transaction `tx`,
+// descending through any parent buckets.  It returns nil if the
+// bucket does not exist.
+func (bk *Bucket) bucket(tx *bolt.Tx) *bolt.Bucket {
+	if bk.parent == nil {
+		return tx.Bucket(bk.Name)
+	}
+	if p := bk.parent.bucket(tx); p != nil {
+		return p.Bucket(bk.Name)
+	}
+	return nil
+}
+
+// with applies `fn` to the bolt bucket for bk within transaction `tx`.
+// It returns ErrBucketNotFound if the bucket does not exist.
+func (bk *Bucket) with(tx *bolt.Tx, fn func(b *bolt.Bucket) error) error {
+	b := bk.bucket(tx)
+	if b == nil {
+		path := bytes.Join(bk.Path(), []byte("/"))
+		return fmt.Errorf("bucket %q: %w", path, ErrBucketNotFound)
+	}
+	return fn(b)
+}
+
+// view applies `fn` to the bolt bucket in a read-only transaction,
+// or in the bound transaction if bk is bound to one.
+func (bk *Bucket) view(fn func(b *bolt.Bucket) error) error {
+	if bk.tx != nil {
+		return bk.with(bk.tx, fn)
+	}
+	return bk.db.View(func(tx *bolt.Tx) error {
+		return bk.with(tx, fn)
+	})
+}
+
+// update applies `fn` to the bolt bucket in a read-write transaction,
+// or in the bound transaction if bk is bound to one.
+func (bk *Bucket) update(fn func(b *bolt.Bucket) error) error {
+	if bk.tx != nil {
+		return bk.with(bk.tx, fn)
+	}
+	return bk.db.Update(func(tx *bolt.Tx) error {
+		return bk.with(tx, fn)
+	})
+}
+
+// get returns the value for key `k` in b, bk's bolt bucket, or nil if
+// the key does not exist or has expired.
+func (bk *Bucket) get(b *bolt.Bucket, k []byte) []byte {
+	v := b.Get(k)
+	if v == nil {
+		return nil
+	}
+	if keys, _ := expiry(b.Tx(), bk.Path()); keys != nil {
+		if expired(keys.Get(k), bk.db.now()) {
+			return nil
+		}
+	}
+	return v
+}
+
+// put puts value `v` with key `k` in b, bk's bolt bucket.  All writes
+// to a bucket go through put and delete, which keep the bookkeeping
+// for the bucket (expiry times, indexes, &c.) up to date.  A nil value
+// is put as an empty one, as bolt does, rather than deleting the key.
+func (bk *Bucket) put(b *bolt.Bucket, k, v []byte) error {
+	if v == nil {
+		v = []byte{}
+	}
+	return bk.write(b, k, v, false)
+}
+
+// delete removes key `k` from b, bk's bolt bucket.
+func (bk *Bucket) delete(b *bolt.Bucket, k []byte) error {
+	return bk.write(b, k, nil, false)
+}
+
+// expire removes expired key `k` from b, bk's bolt bucket, for Sweep.
+// Unlike delete, it reports the deletion of the expired value.
+func (bk *Bucket) expire(b *bolt.Bucket, k []byte) error {
+	return bk.write(b, k, nil, true)
+}
+
+// write puts value `v` with key `k` in b, bk's bolt bucket, or deletes
+// the key if `v` is nil.  `sweep` is set when deleting an expired key.
+func (bk *Bucket) write(b *bolt.Bucket, k, v []byte, sweep bool) error {
+	path := bk.Path()
+	tx := b.Tx()
+	indexes := bk.db.indexesOf(path)
+	watching := bk.db.watching()
+	logging := tx.Bucket(logBucket) != nil
+	// The index entries to remove are for the value stored, even if it
+	// has expired, but watchers and the log see the change from the
+	// value visible to readers: nil if it has expired, unless it's being
+	// swept, which they see as its deletion.
+	var stored, old []byte
+	if len(indexes) > 0 || watching || logging {
+		stored = clone(b.Get(k))
+		if sweep || bk.get(b, k) != nil {
+			old = stored
+		}
+	}
+	var err error
+	if v == nil {
+		err = b.Delete(k)
+	} else {
+		err = b.Put(k, v)
+	}
+	if err != nil {
+		return err
+	}
+	if err := clearExpiry(tx, path, k); err != nil {
+		return err
+	}
+	if err := reindex(tx, path, indexes, k, stored, v); err != nil {
+		return err
+	}
+	if logging {
+		if err := bk.db.appendLog(tx, path, k, old, v); err != nil {
+			return err
+		}
+	}
+	if watching {
+		bk.db.notify(tx, path, k, old, v)
+	}
+	return nil
+}
+
+// Path returns the names of the buckets leading to bk, starting with
+// the top-level bucket and ending with bk's own name.
+func (bk *Bucket) Path() [][]byte {
+	if bk.parent == nil {
+		return [][]byte{bk.Name}
+	}
+	return append(bk.parent.Path(), bk.Name)
+}
+
+// New creates/opens a named bucket nested within bk.
+func (bk *Bucket) New(name []byte) (*Bucket, error) {
+	err := bk.update(func(b *bolt.Bucket) error {
+		_, err := bk.db.createBucket(b.Tx(), b, append(bk.Path(), name))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Bucket{db: bk.db, tx: bk.tx, parent: bk, Name: name}, nil
+}
+
+// Buckets returns the buckets nested within bk.
+func (bk *Bucket) Buckets() (children []*Bucket, err error) {
+	err = bk.view(func(b *bolt.Bucket) error {
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if v == nil {
+				name := make([]byte, len(k))
+				copy(name, k)
+				child := &Bucket{db: bk.db, tx: bk.tx, parent: bk, Name: name}
+				children = append(children, child)
+			}
+		}
+		return nil
+	})
+	return children, err
+}
+
+// DeleteBucket removes the named bucket nested within bk.
+func (bk *Bucket) DeleteBucket(name []byte) error {
+	return bk.update(func(b *bolt.Bucket) error {
+		return bk.db.deleteBucket(b.Tx(), b, append(bk.Path(), name))
+	})
 }
 
 // Put inserts value `v` with key `k`.
 func (bk *Bucket) Put(k, v []byte) error {
-	return bk.db.Update(func(tx *bolt.Tx) error {
-		return tx.Bucket(bk.Name).Put(k, v)
+	return bk.update(func(b *bolt.Bucket) error {
+		return bk.put(b, k, v)
 	})
 }
 
 // PutNX (put-if-not-exists) inserts value `v` with key `k`
 // if key doesn't exist.
 func (bk *Bucket) PutNX(k, v []byte) error {
-	v, err := bk.Get(k)
-	if v != nil || err != nil {
-		return err
-	}
-	return bk.db.Update(func(tx *bolt.Tx) error {
-		return tx.Bucket(bk.Name).Put(k, v)
+	_, err := bk.PutIf(k, v, func(old []byte) bool { return old == nil })
+	return err
+}
+
+// PutXX (put-if-exists) updates the value for key `k` with `v`
+// if key already exists.  It reports whether the value was updated.
+func (bk *Bucket) PutXX(k, v []byte) (bool, error) {
+	return bk.PutIf(k, v, func(old []byte) bool { return old != nil })
+}
+
+// CompareAndSwap puts value `new` with key `k` if the current value
+// for `k` equals `old`.  A nil `old` value matches a missing key.
+// It reports whether the swap took place.
+func (bk *Bucket) CompareAndSwap(k, old, new []byte) (bool, error) {
+	return bk.PutIf(k, new, func(cur []byte) bool {
+		return matches(cur, old)
 	})
 }
 
+// PutIf puts value `v` with key `k` if `cond` holds for the current
+// value of `k` (nil if the key does not exist).  The condition is
+// checked and the value put in the same transaction.  It reports
+// whether the condition held.
+func (bk *Bucket) PutIf(k, v []byte, cond func(old []byte) bool) (bool, error) {
+	ok := false
+	err := bk.update(func(b *bolt.Bucket) error {
+		if ok = cond(bk.get(b, k)); !ok {
+			return nil
+		}
+		return bk.put(b, k, v)
+	})
+	return ok && err == nil, err
+}
+
 // Insert iterates over a slice of k/v pairs, putting each item in
 // the bucket as part of a single transaction.  For large insertions,
 // be sure to pre-sort your items (by Key in byte-sorted order), which
 // will result in much more efficient insertion times and storage costs.
 func (bk *Bucket) Insert(items []struct{ Key, Value []byte }) error {
-	return bk.db.Update(func(tx *bolt.Tx) error {
+	return bk.update(func(b *bolt.Bucket) error {
 		for _, item := range items {
-			tx.Bucket(bk.Name).Put(item.Key, item.Value)
+			if err := bk.put(b, item.Key, item.Value); err != nil {
+				return err
+			}
 		}
 		return nil
 	})
@@ -100,32 +347,101 @@ func (bk *Bucket) Insert(items []struct{ Key, Value []byte }) error {
 // Unlike Insert, however, InsertNX will not update the value for an
 // existing key.
 func (bk *Bucket) InsertNX(items []struct{ Key, Value []byte }) error {
-	return bk.db.Update(func(tx *bolt.Tx) error {
+	return bk.update(func(b *bolt.Bucket) error {
 		for _, item := range items {
-			v, _ := bk.Get(item.Key)
-			if v == nil {
-				tx.Bucket(bk.Name).Put(item.Key, item.Value)
+			if bk.get(b, item.Key) == nil {
+				if err := bk.put(b, item.Key, item.Value); err != nil {
+					return err
+				}
 			}
 		}
 		return nil
 	})
 }
 
-// Delete removes key `k`.
+// Update applies `fn` to the current value of key `k` (nil if the key
+// does not exist) and puts the value returned in its place, all within
+// a single transaction.  If `fn` returns a nil value, the key is deleted.
+// If `fn` returns an error, nothing is updated and the error is returned.
+func (bk *Bucket) Update(k []byte, fn func(old []byte) ([]byte, error)) error {
+	return bk.UpdateKeys([][]byte{k}, func(_, old []byte) ([]byte, error) {
+		return fn(old)
+	})
+}
+
+// UpdateKeys is like Update, but applies `fn` to each of the given keys
+// as part of a single transaction.
+func (bk *Bucket) UpdateKeys(keys [][]byte,
+	fn func(k, old []byte) ([]byte, error)) error {
+
+	return bk.update(func(b *bolt.Bucket) error {
+		for _, k := range keys {
+			if len(k) == 0 {
+				return ErrEmptyKey
+			}
+			var old []byte
+			if v := bk.get(b, k); v != nil {
+				old = make([]byte, len(v))
+				copy(old, v)
+			}
+			new, err := fn(k, old)
+			if err != nil {
+				return err
+			}
+			if new == nil {
+				err = bk.delete(b, k)
+			} else {
+				err = bk.put(b, k, new)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete removes key `k`.  Deleting a key that does not exist is
+// not an error.
 func (bk *Bucket) Delete(k []byte) error {
-	return bk.db.Update(func(tx *bolt.Tx) error {
-		return tx.Bucket(bk.Name).Delete(k)
+	if len(k) == 0 {
+		return ErrEmptyKey
+	}
+	return bk.update(func(b *bolt.Bucket) error {
+		return bk.delete(b, k)
+	})
+}
+
+// DeleteIf removes key `k` if its current value equals `expected`.
+// It reports whether the key was removed.
+func (bk *Bucket) DeleteIf(k, expected []byte) (bool, error) {
+	if len(k) == 0 {
+		return false, ErrEmptyKey
+	}
+	ok := false
+	err := bk.update(func(b *bolt.Bucket) error {
+		cur := bk.get(b, k)
+		if ok = cur != nil && matches(cur, expected); !ok {
+			return nil
+		}
+		return bk.delete(b, k)
 	})
+	return ok && err == nil, err
 }
 
-// Get retrieves the value for key `k`.
+// Get retrieves the value for key `k`.  It returns ErrKeyNotFound
+// if the key does not exist.
 func (bk *Bucket) Get(k []byte) (value []byte, err error) {
-	err = bk.db.View(func(tx *bolt.Tx) error {
-		v := tx.Bucket(bk.Name).Get(k)
-		if v != nil {
-			value = make([]byte, len(v))
-			copy(value, v)
+	if len(k) == 0 {
+		return nil, ErrEmptyKey
+	}
+	err = bk.view(func(b *bolt.Bucket) error {
+		v := bk.get(b, k)
+		if v == nil {
+			return ErrKeyNotFound
 		}
+		value = make([]byte, len(v))
+		copy(value, v)
 		return nil
 	})
 	return value, err
@@ -134,8 +450,8 @@ func (bk *Bucket) Get(k []byte) (value []byte, err error) {
 // Items returns a slice of key/value pairs.  Each k/v pair in the slice
 // is of type Item (`struct{ Key, Value []byte }`).
 func (bk *Bucket) Items() (items []Item, err error) {
-	return items, bk.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(bk.Name).Cursor()
+	return items, bk.view(func(b *bolt.Bucket) error {
+		c := bk.cursor(b)
 		var key, value []byte
 		for k, v := c.First(); k != nil; k, v = c.Next() {
 			if v != nil {
@@ -154,8 +470,8 @@ func (bk *Bucket) Items() (items []Item, err error) {
 // a given prefix.  Each k/v pair in the slice is of type Item
 // (`struct{ Key, Value []byte }`).
 func (bk *Bucket) PrefixItems(pre []byte) (items []Item, err error) {
-	err = bk.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(bk.Name).Cursor()
+	err = bk.view(func(b *bolt.Bucket) error {
+		c := bk.cursor(b)
 		var key, value []byte
 		for k, v := c.Seek(pre); bytes.HasPrefix(k, pre); k, v = c.Next() {
 			if v != nil {
@@ -173,10 +489,12 @@ func (bk *Bucket) PrefixItems(pre []byte) (items []Item, err error) {
 
 // RangeItems returns a slice of key/value pairs for all keys within
 // a given range.  Each k/v pair in the slice is of type Item
-// (`struct{ Key, Value []byte }`).
+// (`struct{ Key, Value []byte }`).  Both bounds are inclusive, and a nil
+// bound leaves that end of the range open.  Use a RangeScanner to
+// exclude either bound.
 func (bk *Bucket) RangeItems(min []byte, max []byte) (items []Item, err error) {
-	err = bk.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(bk.Name).Cursor()
+	err = bk.view(func(b *bolt.Bucket) error {
+		c := bk.cursor(b)
 		var key, value []byte
 		for k, v := c.Seek(min); isBefore(k, max); k, v = c.Next() {
 			if v != nil {
@@ -192,42 +510,58 @@ func (bk *Bucket) RangeItems(min []byte, max []byte) (items []Item, err error) {
 	return items, err
 }
 
-// Map applies `do` on each key/value pair.
+// Map applies `do` on each key/value pair.  If `do` returns an error,
+// the iteration stops and Map returns the error, unless it's
+// ErrStopIteration.
 func (bk *Bucket) Map(do func(k, v []byte) error) error {
-	return bk.db.View(func(tx *bolt.Tx) error {
-		return tx.Bucket(bk.Name).ForEach(do)
-	})
+	return stopped(bk.view(func(b *bolt.Bucket) error {
+		c := bk.cursor(b)
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if err := do(k, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
 }
 
 // MapPrefix applies `do` on each k/v pair of keys with prefix.
+// Errors returned by `do` are handled as in Map.
 func (bk *Bucket) MapPrefix(do func(k, v []byte) error, pre []byte) error {
-	return bk.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(bk.Name).Cursor()
+	return stopped(bk.view(func(b *bolt.Bucket) error {
+		c := bk.cursor(b)
 		for k, v := c.Seek(pre); bytes.HasPrefix(k, pre); k, v = c.Next() {
-			do(k, v)
+			if err := do(k, v); err != nil {
+				return err
+			}
 		}
 		return nil
-	})
+	}))
 }
 
-// MapRange applies `do` on each k/v pair of keys within range.
+// MapRange applies `do` on each k/v pair of keys within range, with
+// bounds as in RangeItems.
+// Errors returned by `do` are handled as in Map.
 func (bk *Bucket) MapRange(do func(k, v []byte) error, min, max []byte) error {
-	return bk.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(bk.Name).Cursor()
+	return stopped(bk.view(func(b *bolt.Bucket) error {
+		c := bk.cursor(b)
 		for k, v := c.Seek(min); isBefore(k, max); k, v = c.Next() {
-			do(k, v)
+			if err := do(k, v); err != nil {
+				return err
+			}
 		}
 		return nil
-	})
+	}))
 }
 
 // NewPrefixScanner initializes a new prefix scanner.
 func (bk *Bucket) NewPrefixScanner(pre []byte) *PrefixScanner {
-	return &PrefixScanner{bk.db, bk.Name, pre}
+	return &PrefixScanner{bk, bk.Name, pre}
 }
 
 // NewRangeScanner initializes a new range scanner.  It takes a `min` and a
-// `max` key for specifying the range paramaters.
+// `max` key for specifying the range paramaters.  Both are inclusive, and
+// either may be nil to leave that end of the range open.
 func (bk *Bucket) NewRangeScanner(min, max []byte) *RangeScanner {
-	return &RangeScanner{bk.db, bk.Name, min, max}
+	return &RangeScanner{bk: bk, BucketName: bk.Name, Min: min, Max: max}
 }
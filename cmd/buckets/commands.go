@@ -0,0 +1,568 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/joyrexus/buckets"
+)
+
+// ls lists the top-level buckets, or those nested in a bucket, with
+// their stats.
+func ls(c *cli, args []string) error {
+	fs := c.flags("ls")
+	c.outputFlags(fs)
+	args, err := c.parse(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	db, err := c.open(args[0], false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var infos []buckets.BucketInfo
+	if len(args) == 1 {
+		if infos, err = db.Buckets(); err != nil {
+			return err
+		}
+	} else {
+		bk, err := openBucket(db, args[1])
+		if err != nil {
+			return err
+		}
+		children, err := bk.Buckets()
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			info, err := child.Info()
+			if err != nil {
+				return err
+			}
+			infos = append(infos, info)
+		}
+	}
+	p := newPrinter(c.stdout, c.format,
+		"BUCKET", "KEYS", "BUCKETS", "DEPTH", "SIZE", "CREATED")
+	for _, info := range infos {
+		if err := p.bucket(info); err != nil {
+			return err
+		}
+	}
+	return p.flush()
+}
+
+// get prints the value of a key: as is, for the table format.
+func get(c *cli, args []string) error {
+	fs := c.flags("get")
+	c.keyFlags(fs)
+	c.outputFlags(fs)
+	args, err := c.parse(fs, args, 3, 3)
+	if err != nil {
+		return err
+	}
+	k, err := c.bytes(args[2])
+	if err != nil {
+		return err
+	}
+	db, err := c.open(args[0], false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	bk, err := openBucket(db, args[1])
+	if err != nil {
+		return err
+	}
+	v, err := bk.Get(k)
+	if err != nil {
+		return err
+	}
+	switch c.format {
+	case "json", "hex":
+		return newPrinter(c.stdout, c.format).item(k, v)
+	}
+	_, err = fmt.Fprintf(c.stdout, "%s\n", v)
+	return err
+}
+
+// put puts a value, creating the bucket if needed.
+func put(c *cli, args []string) error {
+	fs := c.flags("put")
+	c.keyFlags(fs)
+	args, err := c.parse(fs, args, 4, 4)
+	if err != nil {
+		return err
+	}
+	k, err := c.bytes(args[2])
+	if err != nil {
+		return err
+	}
+	var v []byte
+	if args[3] == "-" {
+		if v, err = io.ReadAll(c.stdin); err != nil {
+			return err
+		}
+		if c.hex {
+			v, err = decodeHex(strings.TrimSpace(string(v)))
+		}
+	} else {
+		v, err = c.bytes(args[3])
+	}
+	if err != nil {
+		return err
+	}
+	db, err := c.open(args[0], true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	bk, err := db.NewPath(splitPath(args[1])...)
+	if err != nil {
+		return err
+	}
+	return bk.Put(k, v)
+}
+
+// del deletes keys.
+func del(c *cli, args []string) error {
+	fs := c.flags("del")
+	c.keyFlags(fs)
+	args, err := c.parse(fs, args, 3, -1)
+	if err != nil {
+		return err
+	}
+	var keys [][]byte
+	for _, arg := range args[2:] {
+		k, err := c.bytes(arg)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, k)
+	}
+	db, err := c.open(args[0], true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	bk, err := openBucket(db, args[1])
+	if err != nil {
+		return err
+	}
+	return bk.UpdateKeys(keys, func(_, _ []byte) ([]byte, error) {
+		return nil, nil
+	})
+}
+
+// A selection selects the items of a bucket to scan or count, per the
+// -prefix, -min and -max flags.
+type selection struct {
+	prefix, min, max string
+	reverse          bool
+}
+
+// flags adds the selection flags to `fs`.
+func (sel *selection) flags(fs *flag.FlagSet) {
+	fs.StringVar(&sel.prefix, "prefix", "", "select keys with this prefix")
+	fs.StringVar(&sel.min, "min", "", "select keys from this one")
+	fs.StringVar(&sel.max, "max", "", "select keys up to this one")
+}
+
+// mapper returns a func mapping over the selected items of `bk`.
+func (sel *selection) mapper(c *cli, bk *buckets.Bucket) (
+	func(do func(k, v []byte) error) error, error) {
+
+	arg := func(s string) ([]byte, error) {
+		if s == "" {
+			return nil, nil
+		}
+		return c.bytes(s)
+	}
+	pre, err := arg(sel.prefix)
+	if err != nil {
+		return nil, err
+	}
+	min, err := arg(sel.min)
+	if err != nil {
+		return nil, err
+	}
+	max, err := arg(sel.max)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case pre != nil && (min != nil || max != nil):
+		return nil, errors.New("-prefix can't be used with -min or -max")
+	case pre != nil:
+		ps := bk.NewPrefixScanner(pre)
+		if sel.reverse {
+			return ps.ReverseMap, nil
+		}
+		return ps.Map, nil
+	case min != nil || max != nil:
+		rs := bk.NewRangeScanner(min, max)
+		if sel.reverse {
+			return rs.ReverseMap, nil
+		}
+		return rs.Map, nil
+	case sel.reverse:
+		return bk.ReverseMap, nil
+	}
+	return bk.Map, nil
+}
+
+// scan prints the selected items.
+func scan(c *cli, args []string) error {
+	var sel selection
+	var limit int
+	fs := c.flags("scan")
+	sel.flags(fs)
+	fs.BoolVar(&sel.reverse, "reverse", false, "scan in descending key order")
+	fs.IntVar(&limit, "limit", 0, "print at most this many items")
+	c.keyFlags(fs)
+	c.outputFlags(fs)
+	args, err := c.parse(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	db, err := c.open(args[0], false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	bk, err := openBucket(db, args[1])
+	if err != nil {
+		return err
+	}
+	m, err := sel.mapper(c, bk)
+	if err != nil {
+		return err
+	}
+	p := newPrinter(c.stdout, c.format, "KEY", "VALUE")
+	n := 0
+	err = m(func(k, v []byte) error {
+		if v == nil {
+			return nil // a nested bucket
+		}
+		if limit > 0 && n == limit {
+			return buckets.ErrStopIteration
+		}
+		n++
+		return p.item(k, v)
+	})
+	if err != nil {
+		return err
+	}
+	return p.flush()
+}
+
+// count prints the number of selected items.
+func count(c *cli, args []string) error {
+	var sel selection
+	fs := c.flags("count")
+	sel.flags(fs)
+	c.keyFlags(fs)
+	args, err := c.parse(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	db, err := c.open(args[0], false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	bk, err := openBucket(db, args[1])
+	if err != nil {
+		return err
+	}
+	m, err := sel.mapper(c, bk)
+	if err != nil {
+		return err
+	}
+	n := 0
+	err = m(func(k, v []byte) error {
+		if v != nil {
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(c.stdout, n)
+	return err
+}
+
+// encoding returns the named encoding of keys and values in JSON lines.
+func encoding(name string) (buckets.Encoding, error) {
+	switch name {
+	case "base64":
+		return buckets.Base64, nil
+	case "utf8":
+		return buckets.UTF8, nil
+	}
+	return 0, fmt.Errorf("invalid encoding %q", name)
+}
+
+// columns splits a comma-separated list of CSV columns.
+func columns(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+// export writes the items of a bucket, or of the whole database, to
+// stdout as JSON lines, or as CSV.
+func export(c *cli, args []string) error {
+	var (
+		enc  string
+		csv  bool
+		cols string
+	)
+	fs := c.flags("export")
+	fs.StringVar(&enc, "enc", "base64", "`encoding` of keys and values: base64 or utf8")
+	fs.BoolVar(&csv, "csv", false, "write CSV rather than JSON lines")
+	fs.StringVar(&cols, "columns", "", "for CSV, comma-separated `fields` of JSON values to write as columns")
+	args, err := c.parse(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	e, err := encoding(enc)
+	if err != nil {
+		return err
+	}
+	if csv && len(args) == 1 {
+		return errors.New("CSV exports need a bucket")
+	}
+	db, err := c.open(args[0], false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if len(args) == 1 {
+		return db.Export(c.stdout, e)
+	}
+	bk, err := openBucket(db, args[1])
+	if err != nil {
+		return err
+	}
+	if csv {
+		return bk.ExportCSV(c.stdout, buckets.CSVExportOptions{
+			Columns: columns(cols),
+		})
+	}
+	return bk.Export(c.stdout, e)
+}
+
+// importItems reads items from stdin, as JSON lines (as written by
+// export) or CSV, and puts them in a bucket, or in the database.
+func importItems(c *cli, args []string) error {
+	var (
+		enc    string
+		opts   buckets.ImportOptions
+		csv    bool
+		key    string
+		cols   string
+		dryRun bool
+	)
+	fs := c.flags("import")
+	fs.StringVar(&enc, "enc", "base64", "`encoding` of keys and values: base64 or utf8")
+	fs.IntVar(&opts.BatchSize, "batch", 1000, "items put per transaction")
+	fs.BoolVar(&opts.NoOverwrite, "nx", false, "skip keys that already exist")
+	fs.BoolVar(&csv, "csv", false, "read CSV rather than JSON lines")
+	fs.StringVar(&key, "key", "", "for CSV, key `template`, e.g., {day}/{id}")
+	fs.StringVar(&cols, "columns", "", "for CSV, comma-separated `columns` making up the values (default all)")
+	fs.BoolVar(&dryRun, "dry-run", false, "for CSV, check the rows without importing them")
+	args, err := c.parse(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	if opts.Encoding, err = encoding(enc); err != nil {
+		return err
+	}
+	if csv && len(args) == 1 {
+		return errors.New("CSV imports need a bucket")
+	}
+	if dryRun && !csv {
+		return errors.New("-dry-run is only for CSV imports")
+	}
+	if dryRun {
+		bk, done, err := c.dryRunBucket(args[0], args[1])
+		if err != nil {
+			return err
+		}
+		defer done()
+		return c.importCSV(bk, key, cols, opts, true)
+	}
+	db, err := c.open(args[0], true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if !csv {
+		var n int
+		if len(args) == 1 {
+			n, err = db.Import(c.stdin, opts)
+		} else {
+			var bk *buckets.Bucket
+			if bk, err = db.NewPath(splitPath(args[1])...); err == nil {
+				n, err = bk.Import(c.stdin, opts)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(c.stdout, "imported %d items\n", n)
+		return err
+	}
+
+	bk, err := db.NewPath(splitPath(args[1])...)
+	if err != nil {
+		return err
+	}
+	return c.importCSV(bk, key, cols, opts, false)
+}
+
+// importCSV reads rows of CSV from stdin and puts them in bucket `bk`,
+// with keys per template `key` and values made up of columns `cols`,
+// or just checks them if `dryRun` is set.
+func (c *cli) importCSV(bk *buckets.Bucket, key, cols string,
+	opts buckets.ImportOptions, dryRun bool) error {
+
+	report, err := bk.ImportCSV(c.stdin, buckets.CSVImportOptions{
+		Key:         key,
+		Columns:     columns(cols),
+		BatchSize:   opts.BatchSize,
+		NoOverwrite: opts.NoOverwrite,
+		DryRun:      dryRun,
+	})
+	for _, e := range report.Errors {
+		fmt.Fprintln(c.stderr, e)
+	}
+	verb := "imported"
+	if dryRun {
+		verb = "would import"
+	}
+	fmt.Fprintf(c.stdout, "read %d rows, %s %d\n", report.Rows, verb,
+		report.Imported)
+	if err == nil && len(report.Errors) > 0 {
+		err = fmt.Errorf("skipped %d rows", len(report.Errors))
+	}
+	return err
+}
+
+// dryRunBucket opens the bucket at `path` in database `file` read-only,
+// for a dry run of a CSV import.  If the file or bucket doesn't exist
+// yet (and so would be created by the import), it returns an empty
+// bucket in a temporary database instead.  Call `done` when finished
+// with the bucket.
+func (c *cli) dryRunBucket(file, path string) (bk *buckets.Bucket,
+	done func(), err error) {
+
+	db, err := c.open(file, false)
+	if err == nil {
+		if bk, err = openBucket(db, path); err == nil {
+			return bk, func() { db.Close() }, nil
+		}
+		db.Close()
+	}
+	if !errors.Is(err, os.ErrNotExist) && !errors.Is(err, buckets.ErrBucketNotFound) {
+		return nil, nil, err
+	}
+	dir, err := os.MkdirTemp("", "buckets-")
+	if err != nil {
+		return nil, nil, err
+	}
+	done = func() { os.RemoveAll(dir) }
+	tmp, err := buckets.Open(filepath.Join(dir, "dry-run.db"))
+	if err != nil {
+		done()
+		return nil, nil, err
+	}
+	done = func() {
+		tmp.Close()
+		os.RemoveAll(dir)
+	}
+	if bk, err = tmp.NewPath(splitPath(path)...); err != nil {
+		done()
+		return nil, nil, err
+	}
+	return bk, done, nil
+}
+
+// backup writes a snapshot of the database to a file.
+func backup(c *cli, args []string) error {
+	fs := c.flags("backup")
+	args, err := c.parse(fs, args, 2, 2)
+	if err != nil {
+		return err
+	}
+	db, err := c.open(args[0], false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.BackupTo(args[1])
+}
+
+// compact writes a compacted copy of the database to a file, or
+// compacts it in place, holding the lock on it until it's replaced.
+func compact(c *cli, args []string) error {
+	fs := c.flags("compact")
+	args, err := c.parse(fs, args, 1, 2)
+	if err != nil {
+		return err
+	}
+	src := args[0]
+	dst := src
+	if len(args) == 2 {
+		dst = args[1]
+	}
+	before, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	db, err := c.open(src, dst == src)
+	if err != nil {
+		return err
+	}
+	if dst == src {
+		err = db.Compact()
+	} else {
+		err = db.CompactTo(dst)
+	}
+	if cerr := db.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	after, err := os.Stat(dst)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.stdout, "%s: %d -> %d bytes\n", dst, before.Size(),
+		after.Size())
+	return err
+}
+
+// openBucket opens the existing bucket at `path`, a slash-separated
+// list of names.
+func openBucket(db *buckets.DB, path string) (*buckets.Bucket, error) {
+	return db.BucketPath(splitPath(path)...)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/joyrexus/buckets"
+)
+
+// tableRows is the number of rows of a table aligned at a time, so that
+// long scans don't have to be buffered in full.
+const tableRows = 500
+
+// A printer prints rows of items (or of bucket stats) in an output
+// format:
+//
+//	table  aligned columns, with a header, and text shown as is if it's
+//	       printable or else quoted, as a Go string
+//	json   a JSON object per line, failing on text that isn't UTF-8
+//	       (which JSON would mangle)
+//	hex    a line per row, with keys and values in hex
+type printer struct {
+	format string
+	w      io.Writer
+	tw     *tabwriter.Writer // for tables
+	header []string
+	rows   int
+}
+
+// newPrinter returns a printer of rows with the named columns.
+func newPrinter(w io.Writer, format string, header ...string) *printer {
+	p := &printer{format: format, w: w, header: header}
+	if format == "table" || format == "" {
+		p.format = "table"
+		p.tw = tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	}
+	return p
+}
+
+// item prints a row for the item with key `k` and value `v`.
+func (p *printer) item(k, v []byte) error {
+	switch p.format {
+	case "json":
+		key, err := text("key", k)
+		if err != nil {
+			return err
+		}
+		value, err := text("value", v)
+		if err != nil {
+			return err
+		}
+		return p.json(map[string]string{"key": key, "value": value})
+	case "hex":
+		_, err := fmt.Fprintf(p.w, "%x %x\n", k, v)
+		return err
+	}
+	return p.row(show(k), show(v))
+}
+
+// bucket prints a row of stats for a bucket.
+func (p *printer) bucket(info buckets.BucketInfo) error {
+	path := info.Bucket.Path()
+	name := path[len(path)-1]
+	keys := info.KeyN
+	nested := info.BucketN - 1 // BucketN counts the bucket itself
+	size := info.BranchInuse + info.LeafInuse + info.InlineBucketInuse
+	created := ""
+	if !info.Created.IsZero() {
+		created = info.Created.Format(time.RFC3339)
+	}
+	switch p.format {
+	case "json":
+		text, err := text("bucket name", name)
+		if err != nil {
+			return err
+		}
+		return p.json(map[string]any{
+			"name":    text,
+			"keys":    keys,
+			"buckets": nested,
+			"depth":   info.Depth,
+			"size":    size,
+			"created": created,
+		})
+	case "hex":
+		_, err := fmt.Fprintf(p.w, "%x %d %d %d %d %s\n", name, keys,
+			nested, info.Depth, size, created)
+		return err
+	}
+	return p.row(show(name), strconv.Itoa(keys), strconv.Itoa(nested),
+		strconv.Itoa(info.Depth), strconv.Itoa(size), created)
+}
+
+// json prints `v` as a line of JSON.
+func (p *printer) json(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(p.w, "%s\n", b)
+	return err
+}
+
+// row adds a row to the table, printing the header before the first
+// row, and aligning rows in blocks of tableRows.
+func (p *printer) row(cols ...string) error {
+	if p.rows%tableRows == 0 {
+		if err := p.tw.Flush(); err != nil {
+			return err
+		}
+		if p.rows == 0 {
+			fmt.Fprintln(p.tw, strings.Join(p.header, "\t"))
+		}
+	}
+	p.rows++
+	_, err := fmt.Fprintln(p.tw, strings.Join(cols, "\t"))
+	return err
+}
+
+// flush prints any rows not yet printed.
+func (p *printer) flush() error {
+	if p.tw == nil {
+		return nil
+	}
+	return p.tw.Flush()
+}
+
+// text returns `b` as text for JSON, or an error if it isn't UTF-8.
+// `what` describes it, for the error.
+func text(what string, b []byte) (string, error) {
+	if !utf8.Valid(b) {
+		return "", fmt.Errorf("%s %q isn't UTF-8 (use -o hex)", what, b)
+	}
+	return string(b), nil
+}
+
+// show returns `b` as text if it's printable, or else quoted.
+func show(b []byte) string {
+	s := string(b)
+	if !utf8.ValidString(s) || strings.IndexFunc(s, notPrintable) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// notPrintable checks whether `r` is not printed as is in tables.
+func notPrintable(r rune) bool {
+	return !unicode.IsPrint(r)
+}
+
+// decodeHex decodes a key or value given in hex.
+func decodeHex(s string) ([]byte, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex %q: %w", s, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A lineReader reads command lines.  If the input is a terminal, it
+// echoes the line as it's typed, with basic editing (backspace, ctrl-U
+// to clear the line, ctrl-C to cancel it, ctrl-D to quit) and tab
+// completion.  Otherwise it just reads lines.
+type lineReader struct {
+	r        *bufio.Reader
+	w        io.Writer
+	fd       int  // of the terminal
+	terminal bool // whether the input is a terminal
+
+	// complete returns completions of the last word in `line`: each
+	// the line with the word completed.
+	complete func(line string) []string
+}
+
+// newLineReader returns a lineReader of `in` (echoing to `out`).
+func newLineReader(in io.Reader, out io.Writer,
+	complete func(line string) []string) *lineReader {
+
+	lr := &lineReader{r: bufio.NewReader(in), w: out, complete: complete}
+	if f, ok := in.(*os.File); ok && isTerminal(int(f.Fd())) {
+		lr.fd, lr.terminal = int(f.Fd()), true
+	}
+	return lr
+}
+
+// readLine reads a line, after printing `prompt` if the input is a
+// terminal.  It returns io.EOF at the end of the input.
+func (lr *lineReader) readLine(prompt string) (string, error) {
+	if !lr.terminal {
+		line, err := lr.r.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return strings.TrimRight(line, "\r\n"), err
+	}
+	restore, err := makeRaw(lr.fd)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	var line []rune
+	redraw := func() {
+		fmt.Fprintf(lr.w, "\r\x1b[K%s%s", prompt, string(line))
+	}
+	redraw()
+	for {
+		r, _, err := lr.r.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(lr.w, "\r\n")
+			return string(line), nil
+		case 3: // ctrl-C
+			fmt.Fprint(lr.w, "^C\r\n")
+			return "", nil
+		case 4: // ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(lr.w, "\r\n")
+				return "", io.EOF
+			}
+		case 8, 127: // backspace
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				redraw()
+			}
+		case 21: // ctrl-U
+			line = line[:0]
+			redraw()
+		case '\t':
+			line = lr.tab(line)
+			redraw()
+		case 27: // escape sequences (e.g., arrow keys) aren't supported
+			lr.skipEscape()
+		default:
+			if unicode.IsPrint(r) {
+				line = append(line, r)
+				fmt.Fprint(lr.w, string(r))
+			}
+		}
+	}
+}
+
+// tab completes the last word of `line`.  If there's more than one
+// completion, it completes as much as they have in common and, if
+// that's nothing more, lists them.
+func (lr *lineReader) tab(line []rune) []rune {
+	if lr.complete == nil {
+		return line
+	}
+	completions := lr.complete(string(line))
+	switch len(completions) {
+	case 0:
+		return line
+	case 1:
+		return []rune(completions[0])
+	}
+	common := completions[0]
+	for _, s := range completions[1:] {
+		for !strings.HasPrefix(s, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	for !utf8.ValidString(common) {
+		common = common[:len(common)-1]
+	}
+	if len(common) > len(string(line)) {
+		return []rune(common)
+	}
+	fmt.Fprint(lr.w, "\r\n")
+	start := strings.LastIndexByte(string(line), ' ') + 1
+	for _, s := range completions {
+		fmt.Fprintf(lr.w, "%s  ", strings.TrimSpace(s[start:]))
+	}
+	fmt.Fprint(lr.w, "\r\n")
+	return line
+}
+
+// skipEscape skips the rest of an escape sequence: e.g., "[A" for the
+// up arrow.
+func (lr *lineReader) skipEscape() {
+	if b, err := lr.r.ReadByte(); err != nil || b != '[' && b != 'O' {
+		return
+	}
+	for {
+		b, err := lr.r.ReadByte()
+		if err != nil || b >= 0x40 && b <= 0x7e {
+			return
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// Command buckets inspects and edits buckets database files.
+//
+// Usage:
+//
+//	buckets <command> [flags] FILE [args]
+//
+// The commands are:
+//
+//	ls       FILE [BUCKET]          list buckets, with stats
+//	get      FILE BUCKET KEY        print the value of a key
+//	put      FILE BUCKET KEY VALUE  put a value (read from stdin if "-")
+//	del      FILE BUCKET KEY...     delete keys
+//	scan     FILE BUCKET            print items, e.g., with a given prefix
+//	count    FILE BUCKET            count items, e.g., with a given prefix
+//	export   FILE [BUCKET]          write items to stdout as JSON lines or CSV
+//	import   FILE [BUCKET]          read items from stdin as JSON lines or CSV
+//	backup   FILE DEST              write a snapshot of the database to DEST
+//	compact  FILE [DEST]            write a compacted copy to DEST, or in place
+//	shell    FILE                   start an interactive session
+//
+// Run "buckets <command> -h" for a command's flags.
+//
+// BUCKET is the slash-separated path of a bucket, e.g., "users/42/sessions".
+// Keys and values are given as text, or in hex with -x.  Items are
+// printed as a table, as JSON lines, or in hex, as per -o.  (JSON is only
+// for text: use hex for keys and values that aren't UTF-8.)
+//
+// Commands that only read the database (ls, get, scan, count, export and
+// backup) open it read-only, so they can run alongside other readers.
+// Every command gives up if it can't lock the file within -timeout, so
+// it won't hang while another process has the database open for writing.
+//
+// The shell command reads commands from the terminal (with tab
+// completion of keys and bucket names) or from stdin, and runs them on
+// the database: e.g., cd into a bucket, scan it a page at a time, and
+// get and put items, with begin, commit and rollback to make several
+// changes in a single transaction.  Run "help" in the shell for its
+// commands.  The shell only holds the database open while a command
+// runs, or while a transaction is open.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/joyrexus/buckets"
+)
+
+// A command is a subcommand of buckets.
+type command struct {
+	name  string
+	args  string // synopsis of the arguments
+	short string // one-line description
+	run   func(c *cli, args []string) error
+}
+
+var commands []*command
+
+func init() {
+	// Set here rather than in the declaration, since help refers to it.
+	commands = []*command{
+		{"ls", "FILE [BUCKET]", "list buckets, with stats", ls},
+		{"get", "FILE BUCKET KEY", "print the value of a key", get},
+		{"put", "FILE BUCKET KEY VALUE", "put a value", put},
+		{"del", "FILE BUCKET KEY...", "delete keys", del},
+		{"scan", "FILE BUCKET", "print items", scan},
+		{"count", "FILE BUCKET", "count items", count},
+		{"export", "FILE [BUCKET]", "write items to stdout", export},
+		{"import", "FILE [BUCKET]", "read items from stdin", importItems},
+		{"backup", "FILE DEST", "write a snapshot to DEST", backup},
+		{"compact", "FILE [DEST]", "compact to DEST, or in place", compact},
+		{"shell", "FILE", "start an interactive session", shellCmd},
+		{"help", "[COMMAND]", "show help", help},
+	}
+}
+
+// errUsage is returned by commands called with bad flags or arguments,
+// after printing their usage.
+var errUsage = errors.New("usage")
+
+// A cli holds the streams commands read from and write to, and the
+// settings of the flags they share.
+type cli struct {
+	stdin          io.Reader
+	stdout, stderr io.Writer
+
+	timeout time.Duration // to lock the database file
+	hex     bool          // keys and values are given in hex
+	format  string        // output format
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command named by args[0], returning the exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &cli{stdin: stdin, stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		c.usage()
+		return 2
+	}
+	cmd := lookup(args[0])
+	if cmd == nil {
+		fmt.Fprintf(stderr, "buckets: unknown command %q\n", args[0])
+		c.usage()
+		return 2
+	}
+	switch err := cmd.run(c, args[1:]); {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	default:
+		fmt.Fprintf(stderr, "buckets %s: %v\n", cmd.name, err)
+		return 1
+	}
+}
+
+// lookup returns the named command, or nil if there's no such command.
+func lookup(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// usage prints the list of commands.
+func (c *cli) usage() {
+	fmt.Fprintf(c.stderr, "usage: buckets <command> [flags] FILE [args]\n\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(c.stderr, "  %-8s %-22s %s\n", cmd.name, cmd.args, cmd.short)
+	}
+	fmt.Fprintf(c.stderr, "\nRun \"buckets <command> -h\" for a command's flags.\n")
+}
+
+// help prints the usage of a command, or the list of commands.
+func help(c *cli, args []string) error {
+	if len(args) == 0 {
+		c.usage()
+		return nil
+	}
+	cmd := lookup(args[0])
+	if cmd == nil {
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	if err := cmd.run(c, []string{"-h"}); !errors.Is(err, errUsage) {
+		return err
+	}
+	return nil
+}
+
+// flags returns a flag set for the named command, with the -timeout
+// flag that every command has.
+func (c *cli) flags(name string) *flag.FlagSet {
+	cmd := lookup(name)
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "usage: buckets %s [flags] %s\n\n", name, cmd.args)
+		fmt.Fprintf(c.stderr, "%s.\n\nFlags:\n", capitalize(cmd.short))
+		fs.PrintDefaults()
+	}
+	fs.DurationVar(&c.timeout, "timeout", time.Second,
+		"how long to wait to lock the database file")
+	return fs
+}
+
+// keyFlags adds the -x flag, for commands that take keys or values.
+func (c *cli) keyFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&c.hex, "x", false, "keys and values are given in hex")
+}
+
+// outputFlags adds the -o flag, for commands that print items.
+func (c *cli) outputFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.format, "o", "table", "output `format`: table, json or hex")
+}
+
+// parse parses `args` with `fs`, returning the positional arguments,
+// of which there must be between `min` and `max` (or more, if max is
+// negative).
+func (c *cli) parse(fs *flag.FlagSet, args []string, min, max int) ([]string,
+	error) {
+
+	if err := fs.Parse(args); err != nil {
+		return nil, errUsage // the flag package has printed the usage
+	}
+	n := fs.NArg()
+	if n < min || max >= 0 && n > max {
+		fs.Usage()
+		return nil, errUsage
+	}
+	switch c.format {
+	case "", "table", "json", "hex":
+	default:
+		fmt.Fprintf(c.stderr, "invalid output format %q\n", c.format)
+		fs.Usage()
+		return nil, errUsage
+	}
+	return fs.Args(), nil
+}
+
+// open opens the database file at `path`, read-only unless `write` is
+// set.
+func (c *cli) open(path string, write bool) (*buckets.DB, error) {
+	if !write {
+		// Don't create a database just to read nothing from it.
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+	}
+	return buckets.OpenWithOptions(path, &buckets.Options{
+		ReadOnly: !write,
+		Timeout:  c.timeout,
+	})
+}
+
+// splitPath splits a slash-separated bucket path into bucket names.
+func splitPath(path string) (names [][]byte) {
+	for _, name := range strings.Split(path, "/") {
+		names = append(names, []byte(name))
+	}
+	return names
+}
+
+// bytes returns the key or value given by argument `s`, which is hex if
+// the -x flag is set.
+func (c *cli) bytes(s string) ([]byte, error) {
+	if c.hex {
+		return decodeHex(s)
+	}
+	return []byte(s), nil
+}
+
+// capitalize returns `s` with its first letter in upper case.
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
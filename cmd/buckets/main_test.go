@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// exec runs the buckets command with `args` and `stdin`, returning what
+// it writes to stdout and its exit status.
+func exec(t *testing.T, stdin string, args ...string) (string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	if code != 0 {
+		t.Logf("buckets %s: %s", strings.Join(args, " "), stderr.String())
+	}
+	return stdout.String(), code
+}
+
+// ok runs the buckets command, failing the test if it fails, and
+// returns what it writes to stdout.
+func ok(t *testing.T, stdin string, args ...string) string {
+	t.Helper()
+	out, code := exec(t, stdin, args...)
+	if code != 0 {
+		t.Fatalf("buckets %s: exit status %d", strings.Join(args, " "), code)
+	}
+	return out
+}
+
+// Ensure that items can be put, read, scanned, counted and deleted.
+func TestItems(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "test.db")
+	for _, day := range []string{"mon", "tue", "wed", "thu"} {
+		ok(t, "", "put", db, "todos", day, "todo on "+day)
+	}
+	ok(t, "milk\tcows", "put", db, "todos", "fri", "-")
+	ok(t, "", "put", "-x", db, "users/42", "00ff", "6869")
+
+	if got := ok(t, "", "get", db, "todos", "mon"); got != "todo on mon\n" {
+		t.Errorf("got %q, want the value as is", got)
+	}
+	if got := ok(t, "", "get", "-x", "-o", "hex", db, "users/42", "00ff"); got != "00ff 6869\n" {
+		t.Errorf("got %q, want hex", got)
+	}
+	if _, code := exec(t, "", "get", db, "todos", "sat"); code != 1 {
+		t.Errorf("got exit status %d for missing key, want 1", code)
+	}
+	if _, code := exec(t, "", "scan", "-o", "json", db, "users/42"); code != 1 {
+		t.Errorf("got exit status %d for JSON of binary key, want 1", code)
+	}
+
+	want := `KEY  VALUE
+fri  "milk\tcows"
+mon  todo on mon
+`
+	if got := ok(t, "", "scan", "-limit", "2", db, "todos"); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	want = `{"key":"wed","value":"todo on wed"}
+{"key":"tue","value":"todo on tue"}
+`
+	got := ok(t, "", "scan", "-min", "tue", "-reverse", "-o", "json", db, "todos")
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if got := ok(t, "", "count", "-prefix", "t", db, "todos"); got != "2\n" {
+		t.Errorf("got count %q, want 2", got)
+	}
+	if _, code := exec(t, "", "count", "-prefix", "t", "-max", "u", db, "todos"); code != 1 {
+		t.Errorf("got exit status %d for -prefix with -max, want 1", code)
+	}
+
+	ok(t, "", "del", db, "todos", "mon", "tue")
+	if got := ok(t, "", "count", db, "todos"); got != "3\n" {
+		t.Errorf("got count %q, want 3", got)
+	}
+
+	// Stats include nested buckets: users has one, which counts as a key,
+	// along with the key in it.
+	var stats []string
+	for _, line := range strings.Split(ok(t, "", "ls", db), "\n")[1:] {
+		if fields := strings.Fields(line); len(fields) > 3 {
+			stats = append(stats, strings.Join(fields[:3], " "))
+		}
+	}
+	if want := []string{"todos 3 0", "users 2 1"}; !reflect.DeepEqual(stats, want) {
+		t.Errorf("got stats %q, want %q", stats, want)
+	}
+	if got := ok(t, "", "ls", "-o", "json", db, "users"); !strings.Contains(got, `"name":"42"`) {
+		t.Errorf("got %s, want stats of nested bucket 42", got)
+	}
+}
+
+// Ensure that reads don't wait long for a writer to release the
+// database, and don't create databases.
+func TestReadOnly(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "test.db")
+	if _, code := exec(t, "", "ls", db); code != 1 {
+		t.Errorf("got exit status %d for missing database, want 1", code)
+	}
+	if _, err := os.Stat(db); err == nil {
+		t.Error("expected ls not to create the database")
+	}
+
+	ok(t, "", "put", db, "todos", "mon", "milk cows")
+	bx, err := buckets.Open(db)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	_, code := exec(t, "", "get", "-timeout", "50ms", db, "todos", "mon")
+	bx.Close()
+	if code != 1 {
+		t.Errorf("got exit status %d while locked, want 1", code)
+	}
+	if got := ok(t, "", "get", db, "todos", "mon"); got != "milk cows\n" {
+		t.Errorf("got %q, want milk cows", got)
+	}
+}
+
+// Ensure that databases can be exported, imported, backed up and
+// compacted.
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	db := filepath.Join(dir, "test.db")
+	ok(t, "", "put", db, "todos", "mon", `{"task":"milk cows"}`)
+	ok(t, "", "put", db, "users/42", "name", "ann")
+
+	dump := ok(t, "", "export", db)
+	copied := filepath.Join(dir, "copy.db")
+	if got := ok(t, dump, "import", copied); got != "imported 2 items\n" {
+		t.Errorf("got %q", got)
+	}
+	if got := ok(t, "", "get", copied, "users/42", "name"); got != "ann\n" {
+		t.Errorf("got %q, want ann", got)
+	}
+	out, code := exec(t, "{oops\n", "import", copied, "todos")
+	if out != "" || code != 1 {
+		t.Errorf("got %q, exit status %d for bad import, want failure", out, code)
+	}
+
+	csv := ok(t, "", "export", "-csv", "-columns", "task", db, "todos")
+	if csv != "key,task\nmon,milk cows\n" {
+		t.Errorf("got CSV %q", csv)
+	}
+	rows := "day,task\ntue,fold laundry\n,feed pigs\n"
+	out, code = exec(t, rows, "import", "-csv", "-key", "{day}", "-dry-run", db, "todos")
+	if out != "read 2 rows, would import 1\n" || code != 1 {
+		t.Errorf("got %q, exit status %d", out, code)
+	}
+	if got := ok(t, "", "count", db, "todos"); got != "1\n" {
+		t.Errorf("got count %q after dry run, want 1", got)
+	}
+	rows = "day,task\ntue,fold laundry\n"
+	if got := ok(t, rows, "import", "-csv", "-key", "{day}", "-dry-run", db, "chores"); got != "read 1 rows, would import 1\n" {
+		t.Errorf("got %q for dry run into a new bucket", got)
+	}
+	fresh := filepath.Join(dir, "fresh.db")
+	if got := ok(t, rows, "import", "-csv", "-key", "{day}", "-dry-run", fresh, "chores"); got != "read 1 rows, would import 1\n" {
+		t.Errorf("got %q for dry run into a new file", got)
+	}
+	if _, err := os.Stat(fresh); !os.IsNotExist(err) {
+		t.Errorf("dry run created %s", fresh)
+	}
+	if _, code := exec(t, "", "count", db, "chores"); code == 0 {
+		t.Error("dry run created bucket chores")
+	}
+
+	backup := filepath.Join(dir, "backup.db")
+	ok(t, "", "backup", db, backup)
+	if got := ok(t, "", "get", backup, "todos", "mon"); got != "{\"task\":\"milk cows\"}\n" {
+		t.Errorf("got %q from backup", got)
+	}
+	if got := ok(t, "", "compact", db); !strings.HasPrefix(got, db+": ") {
+		t.Errorf("got %q", got)
+	}
+	if got := ok(t, "", "get", db, "users/42", "name"); got != "ann\n" {
+		t.Errorf("got %q after compacting, want ann", got)
+	}
+}
+
+// Ensure that bad commands and arguments fail with usage errors.
+func TestUsage(t *testing.T) {
+	for _, args := range [][]string{
+		{},
+		{"frob"},
+		{"get", "test.db", "todos"},
+		{"scan", "-o", "xml", "test.db", "todos"},
+		{"ls", "-nope", "test.db"},
+	} {
+		if _, code := exec(t, "", args...); code != 2 {
+			t.Errorf("%v: got exit status %d, want 2", args, code)
+		}
+	}
+	if _, code := exec(t, "", "help", "scan"); code != 0 {
+		t.Errorf("got exit status %d for help, want 0", code)
+	}
+}
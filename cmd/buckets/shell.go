@@ -0,0 +1,612 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/joyrexus/buckets"
+)
+
+// shellHelp describes the shell's commands.
+const shellHelp = `Commands:
+
+  ls                 list the buckets here, with their key counts
+  cd PATH            change to a nested bucket: e.g., "users/42", ".." or "/"
+  pwd                print the path of the current bucket
+  get KEY            print a value, pretty-printing JSON
+  put KEY VALUE...   put a value
+  del KEY...         delete keys
+  new NAME           create a bucket here
+  scan [PREFIX]      print the first page of items (with keys having PREFIX)
+  more               print the next page
+  count [PREFIX]     count items (with keys having PREFIX)
+  begin              begin a transaction, holding the database until...
+  commit             the transaction is committed
+  rollback           or rolled back
+  help               print this help
+  exit               quit, rolling back any transaction
+
+Words in double quotes are Go strings, e.g., "milk cows" or "\x00\x01".
+Press tab to complete commands, keys and bucket names.
+`
+
+// shellCompletions is the maximum number of completions listed.
+const shellCompletions = 100
+
+// A shell is an interactive session on a database file.  Each command
+// opens the database for as long as it runs, read-only unless it writes,
+// so the shell doesn't keep other processes from using the database.
+// Between begin and commit (or rollback), the database is held open in
+// a read-write transaction.
+type shell struct {
+	c        *cli
+	file     string
+	cwd      [][]byte // path of the current bucket; empty at the top
+	pageSize int
+
+	db *buckets.DB // open during a transaction
+	tx *buckets.Tx
+
+	scan *scanState // of the last scan, if there are more pages
+}
+
+// A scanState records where a scan left off.
+type scanState struct {
+	path   [][]byte
+	prefix []byte
+	after  []byte // continuation token
+}
+
+// shellCmd runs an interactive shell.
+func shellCmd(c *cli, args []string) error {
+	var pageSize int
+	fs := c.flags("shell")
+	fs.IntVar(&pageSize, "page", 20, "items per page of scans")
+	args, err := c.parse(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	if pageSize <= 0 {
+		return fmt.Errorf("invalid page size: %d", pageSize)
+	}
+	sh := &shell{c: c, file: args[0], pageSize: pageSize}
+
+	// Check that the database can be opened before starting.
+	if err := sh.do(false, func(tx *buckets.Tx) error { return nil }); err != nil {
+		return err
+	}
+	lr := newLineReader(c.stdin, c.stdout, sh.complete)
+	if lr.terminal {
+		fmt.Fprintf(c.stdout, "Type \"help\" for help.\n")
+	}
+	for {
+		line, err := lr.readLine(sh.prompt())
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			sh.close()
+			return err
+		}
+		cmd, args, err := parseLine(line)
+		if err != nil {
+			fmt.Fprintln(c.stderr, err)
+			continue
+		}
+		if cmd == "" {
+			continue
+		}
+		if cmd == "exit" || cmd == "quit" {
+			break
+		}
+		if err := sh.exec(cmd, args); err != nil {
+			fmt.Fprintf(c.stderr, "%s: %v\n", cmd, err)
+		}
+	}
+	return sh.close()
+}
+
+// prompt returns the prompt: the database file and current bucket,
+// marked if a transaction is open.
+func (sh *shell) prompt() string {
+	tx := ""
+	if sh.tx != nil {
+		tx = " (tx)"
+	}
+	return fmt.Sprintf("%s:/%s%s> ", filepath.Base(sh.file),
+		bytes.Join(sh.cwd, []byte("/")), tx)
+}
+
+// exec runs command `cmd` with `args`.
+func (sh *shell) exec(cmd string, args []string) error {
+	arg := func(i int) []byte {
+		if i < len(args) {
+			return []byte(args[i])
+		}
+		return nil
+	}
+	nargs := map[string][2]int{ // min and max numbers of args
+		"ls": {0, 0}, "cd": {1, 1}, "pwd": {0, 0}, "get": {1, 1},
+		"put": {2, 2}, "del": {1, -1}, "new": {1, 1}, "scan": {0, 1},
+		"more": {0, 0}, "count": {0, 1}, "begin": {0, 0}, "commit": {0, 0},
+		"rollback": {0, 0}, "help": {0, 0},
+	}
+	n, ok := nargs[cmd]
+	if !ok {
+		return errors.New(`unknown command (try "help")`)
+	}
+	if len(args) < n[0] || n[1] >= 0 && len(args) > n[1] {
+		return errors.New(`wrong number of arguments (try "help")`)
+	}
+
+	switch cmd {
+	case "ls":
+		return sh.ls()
+	case "cd":
+		return sh.cd(args[0])
+	case "pwd":
+		fmt.Fprintf(sh.c.stdout, "/%s\n", bytes.Join(sh.cwd, []byte("/")))
+		return nil
+	case "get":
+		return sh.get(arg(0))
+	case "put":
+		return sh.update(func(bk *buckets.Bucket) error {
+			return bk.Put(arg(0), arg(1))
+		})
+	case "del":
+		return sh.update(func(bk *buckets.Bucket) error {
+			for i := range args {
+				if err := bk.Delete(arg(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case "new":
+		return sh.do(true, func(tx *buckets.Tx) error {
+			if len(sh.cwd) == 0 {
+				_, err := tx.New(arg(0))
+				return err
+			}
+			bk, err := tx.BucketPath(sh.cwd...)
+			if err != nil {
+				return err
+			}
+			_, err = bk.New(arg(0))
+			return err
+		})
+	case "scan":
+		sh.scan = &scanState{path: sh.cwd, prefix: arg(0)}
+		return sh.more()
+	case "more":
+		if sh.scan == nil {
+			return errors.New("no more items")
+		}
+		return sh.more()
+	case "count":
+		return sh.count(arg(0))
+	case "begin":
+		return sh.begin()
+	case "commit", "rollback":
+		return sh.finish(cmd == "commit")
+	}
+	fmt.Fprint(sh.c.stdout, shellHelp)
+	return nil
+}
+
+// do runs `fn` in the open transaction, if there is one, or else opens
+// the database and runs `fn` in a new transaction, read-write if `write`
+// is set.
+func (sh *shell) do(write bool, fn func(tx *buckets.Tx) error) error {
+	if sh.tx != nil {
+		return fn(sh.tx)
+	}
+	db, err := sh.c.open(sh.file, write)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if write {
+		return db.Tx(fn)
+	}
+	return db.ReadTx(fn)
+}
+
+// view applies `fn` to the bucket at `path` in a transaction.
+func (sh *shell) view(path [][]byte, fn func(bk *buckets.Bucket) error) error {
+	return sh.do(false, func(tx *buckets.Tx) error {
+		bk, err := bucketAt(tx, path)
+		if err != nil {
+			return err
+		}
+		return fn(bk)
+	})
+}
+
+// update applies `fn` to the current bucket in a read-write transaction.
+func (sh *shell) update(fn func(bk *buckets.Bucket) error) error {
+	return sh.do(true, func(tx *buckets.Tx) error {
+		bk, err := bucketAt(tx, sh.cwd)
+		if err != nil {
+			return err
+		}
+		return fn(bk)
+	})
+}
+
+// bucketAt returns the bucket at `path` within `tx`.
+func bucketAt(tx *buckets.Tx, path [][]byte) (*buckets.Bucket, error) {
+	if len(path) == 0 {
+		return nil, errors.New(`not in a bucket (try "ls" and "cd")`)
+	}
+	return tx.BucketPath(path...)
+}
+
+// ls lists the buckets in the current bucket, or the top-level buckets.
+func (sh *shell) ls() error {
+	p := newPrinter(sh.c.stdout, "table", "BUCKET", "KEYS")
+	err := sh.do(false, func(tx *buckets.Tx) error {
+		infos, err := sh.buckets(tx, sh.cwd)
+		if err != nil {
+			return err
+		}
+		for _, info := range infos {
+			path := info.Bucket.Path()
+			name := path[len(path)-1]
+			if err := p.row(quote(name), strconv.Itoa(info.KeyN)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return p.flush()
+}
+
+// buckets returns info about the buckets in the bucket at `path`, or
+// about the top-level buckets if `path` is empty.
+func (sh *shell) buckets(tx *buckets.Tx, path [][]byte) ([]buckets.BucketInfo,
+	error) {
+
+	if len(path) == 0 {
+		return tx.Buckets()
+	}
+	bk, err := tx.BucketPath(path...)
+	if err != nil {
+		return nil, err
+	}
+	children, err := bk.Buckets()
+	if err != nil {
+		return nil, err
+	}
+	var infos []buckets.BucketInfo
+	for _, child := range children {
+		info, err := child.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// cd changes the current bucket to the one at `path`, which is relative
+// to the current bucket unless it starts with a slash.
+func (sh *shell) cd(path string) error {
+	cwd := resolve(sh.cwd, path)
+	if len(cwd) > 0 {
+		err := sh.do(false, func(tx *buckets.Tx) error {
+			_, err := tx.BucketPath(cwd...)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	sh.cwd = cwd
+	return nil
+}
+
+// resolve returns the path of bucket `path` relative to `cwd`.
+func resolve(cwd [][]byte, path string) [][]byte {
+	if strings.HasPrefix(path, "/") {
+		cwd = nil
+	}
+	resolved := append([][]byte{}, cwd...)
+	for _, name := range strings.Split(path, "/") {
+		switch name {
+		case "", ".":
+		case "..":
+			if len(resolved) > 0 {
+				resolved = resolved[:len(resolved)-1]
+			}
+		default:
+			resolved = append(resolved, []byte(name))
+		}
+	}
+	return resolved
+}
+
+// get prints the value of key `k`, indented if it's JSON, or else as is
+// if it's printable.
+func (sh *shell) get(k []byte) error {
+	var v []byte
+	err := sh.view(sh.cwd, func(bk *buckets.Bucket) (err error) {
+		v, err = bk.Get(k)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	var indented bytes.Buffer
+	if json.Indent(&indented, v, "", "  ") == nil {
+		_, err = fmt.Fprintf(sh.c.stdout, "%s\n", indented.Bytes())
+		return err
+	}
+	_, err = fmt.Fprintln(sh.c.stdout, show(v))
+	return err
+}
+
+// more prints the next page of the current scan.
+func (sh *shell) more() error {
+	s := sh.scan
+	var items []buckets.Item
+	err := sh.view(s.path, func(bk *buckets.Bucket) (err error) {
+		if len(s.prefix) == 0 {
+			items, s.after, err = bk.Page(sh.pageSize, s.after)
+		} else {
+			ps := bk.NewPrefixScanner(s.prefix)
+			items, s.after, err = ps.Page(sh.pageSize, s.after)
+		}
+		return err
+	})
+	if err != nil {
+		sh.scan = nil
+		return err
+	}
+	p := newPrinter(sh.c.stdout, "table", "KEY", "VALUE")
+	for _, item := range items {
+		if item.Value == nil {
+			continue // a nested bucket
+		}
+		if err := p.row(quote(item.Key), show(item.Value)); err != nil {
+			return err
+		}
+	}
+	if err := p.flush(); err != nil {
+		return err
+	}
+	if s.after == nil {
+		sh.scan = nil
+		return nil
+	}
+	_, err = fmt.Fprintln(sh.c.stdout, `-- type "more" for more --`)
+	return err
+}
+
+// count prints the number of items in the current bucket, or of those
+// with keys having prefix `pre`.
+func (sh *shell) count(pre []byte) error {
+	n := 0
+	err := sh.view(sh.cwd, func(bk *buckets.Bucket) error {
+		m := bk.Map
+		if len(pre) > 0 {
+			m = bk.NewPrefixScanner(pre).Map
+		}
+		return m(func(k, v []byte) error {
+			if v != nil {
+				n++
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(sh.c.stdout, n)
+	return err
+}
+
+// begin opens the database and begins a read-write transaction, which
+// lasts until it is committed or rolled back.
+func (sh *shell) begin() error {
+	if sh.tx != nil {
+		return errors.New("already in a transaction")
+	}
+	db, err := sh.c.open(sh.file, true)
+	if err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(true)
+	if err != nil {
+		db.Close()
+		return err
+	}
+	sh.db, sh.tx = db, tx
+	return nil
+}
+
+// finish commits or rolls back the open transaction, closing the
+// database.
+func (sh *shell) finish(commit bool) error {
+	if sh.tx == nil {
+		return errors.New("not in a transaction")
+	}
+	var err error
+	if commit {
+		err = sh.tx.Commit()
+	} else {
+		err = sh.tx.Rollback()
+	}
+	if cerr := sh.db.Close(); err == nil {
+		err = cerr
+	}
+	sh.db, sh.tx = nil, nil
+	return err
+}
+
+// close rolls back any open transaction, as the shell exits.
+func (sh *shell) close() error {
+	if sh.tx == nil {
+		return nil
+	}
+	fmt.Fprintln(sh.c.stderr, "rolling back the open transaction")
+	return sh.finish(false)
+}
+
+// complete returns completions of the last word in `line`: commands,
+// for the first word, bucket names, for cd, and otherwise keys.
+func (sh *shell) complete(line string) []string {
+	start := strings.LastIndexByte(line, ' ') + 1
+	word := line[start:]
+	cmd, _, _ := strings.Cut(line, " ")
+
+	var completions []string
+	add := func(s, suffix string) {
+		if !strings.HasPrefix(s, word) {
+			return
+		}
+		completions = append(completions, line[:start]+s+suffix)
+	}
+	switch {
+	case start == 0:
+		for _, cmd := range shellCommands {
+			add(cmd, " ")
+		}
+	case cmd == "cd":
+		dir := word[:strings.LastIndexByte(word, '/')+1]
+		sh.do(false, func(tx *buckets.Tx) error {
+			infos, err := sh.buckets(tx, resolve(sh.cwd, dir))
+			if err != nil {
+				return err
+			}
+			for _, info := range infos {
+				add(dir+string(info.Bucket.Name), "/")
+			}
+			return nil
+		})
+	case cmd == "put" && start > len("put "):
+		// A value, not a key.
+	case strings.HasPrefix(word, `"`):
+		// Quoted keys aren't completed.
+	case keyCommands[cmd]:
+		pre := []byte(word)
+		sh.view(sh.cwd, func(bk *buckets.Bucket) (err error) {
+			var items []buckets.Item
+			if word == "" {
+				items, _, err = bk.Page(shellCompletions, nil)
+			} else {
+				ps := bk.NewPrefixScanner(pre)
+				items, _, err = ps.Page(shellCompletions, nil)
+			}
+			for _, item := range items {
+				if item.Value == nil {
+					continue // a nested bucket
+				}
+				// The scan matched the prefix, so don't check it again.
+				completions = append(completions, line[:start]+quote(item.Key)+" ")
+			}
+			return err
+		})
+	}
+	sort.Strings(completions)
+	return completions
+}
+
+// keyCommands are the shell's commands taking keys as arguments.
+var keyCommands = map[string]bool{
+	"get": true, "put": true, "del": true, "scan": true, "count": true,
+}
+
+// shellCommands lists the shell's commands, for completion.
+var shellCommands = []string{
+	"begin", "cd", "commit", "count", "del", "exit", "get", "help", "ls",
+	"more", "new", "put", "pwd", "rollback", "scan",
+}
+
+// parseLine splits a command line into the command and its arguments.
+// For put, the arguments are the key and the rest of the line, which is
+// the value (so that, e.g., JSON needn't be quoted).
+func parseLine(line string) (cmd string, args []string, err error) {
+	cmd, rest, err := cut(line)
+	if err != nil || cmd != "put" {
+		args, err = words(rest)
+		return cmd, args, err
+	}
+	k, rest, err := cut(rest)
+	if err != nil || k == "" {
+		return cmd, nil, err
+	}
+	return cmd, []string{k, value(rest)}, nil
+}
+
+// words splits a command line into words, separated by spaces.  Words
+// in double quotes are Go strings, which may contain spaces and escape
+// sequences.
+func words(line string) (words []string, err error) {
+	for {
+		word, rest, err := cut(line)
+		if err != nil || word == "" && rest == "" {
+			return words, err
+		}
+		words = append(words, word)
+		line = rest
+	}
+}
+
+// cut returns the first word of `line` (see words) and the rest of the
+// line after it.
+func cut(line string) (word, rest string, err error) {
+	line = strings.TrimLeft(line, " \t")
+	if line == "" {
+		return "", "", nil
+	}
+	if line[0] != '"' {
+		end := strings.IndexAny(line, " \t")
+		if end < 0 {
+			end = len(line)
+		}
+		return line[:end], line[end:], nil
+	}
+	end := 1
+	for ; end < len(line) && line[end] != '"'; end++ {
+		if line[end] == '\\' {
+			end++
+		}
+	}
+	if end >= len(line) {
+		return "", "", errors.New("unterminated quoted string")
+	}
+	word, err = strconv.Unquote(line[:end+1])
+	if err != nil {
+		return "", "", fmt.Errorf("invalid quoted string %s", line[:end+1])
+	}
+	return word, line[end+1:], nil
+}
+
+// value returns the value given by the rest of a command line: the text
+// as is, or a Go string if it's quoted.
+func value(rest string) string {
+	rest = strings.TrimSpace(rest)
+	if v, err := strconv.Unquote(rest); err == nil && rest[0] == '"' {
+		return v
+	}
+	return rest
+}
+
+// quote returns `k` as typed in the shell: as is, or quoted if it has
+// spaces or isn't printable.
+func quote(k []byte) string {
+	if s := show(k); s == string(k) && !strings.ContainsAny(s, " \t\"") {
+		return s
+	}
+	return strconv.Quote(string(k))
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Ensure that the shell runs commands read from stdin.
+func TestShell(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "test.db")
+	ok(t, "", "put", db, "users/42", "name", "ann")
+
+	script := `new todos
+cd todos
+put mon milk cows
+put tue {"task": "fold laundry", "done": false}
+put "wed day" "feed\tpigs"
+get tue
+scan
+count t
+cd /users
+ls
+cd ../todos
+pwd
+cd nope
+`
+	want := `{
+  "task": "fold laundry",
+  "done": false
+}
+KEY        VALUE
+mon        milk cows
+tue        {"task": "fold laundry", "done": false}
+"wed day"  "feed\tpigs"
+1
+BUCKET  KEYS
+42      1
+/todos
+`
+	if got := ok(t, script, "shell", db); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// Ensure that scans are paged.
+func TestShellPages(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "test.db")
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		ok(t, "", "put", db, "things", k, k)
+	}
+	want := `KEY  VALUE
+a    a
+b    b
+-- type "more" for more --
+KEY  VALUE
+c    c
+d    d
+-- type "more" for more --
+KEY  VALUE
+e    e
+`
+	got := ok(t, "cd things\nscan\nmore\nmore\nmore\n", "shell", "-page", "2", db)
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// Ensure that changes made in a transaction are committed or rolled
+// back together.
+func TestShellTx(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "test.db")
+	ok(t, "", "put", db, "todos", "mon", "milk cows")
+
+	script := `cd todos
+begin
+put tue fold laundry
+del mon
+rollback
+begin
+put wed feed pigs
+commit
+begin
+put thu exit without committing
+`
+	ok(t, script, "shell", db)
+	got := ok(t, "", "scan", db, "todos")
+	want := `KEY  VALUE
+mon  milk cows
+wed  feed pigs
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// Ensure that commands, bucket names and keys are completed.
+func TestShellComplete(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "test.db")
+	ok(t, "", "put", db, "users/42", "name", "ann")
+	ok(t, "", "put", db, "users/43", "name", "bob")
+	ok(t, "", "put", db, "users", "nick", "x")
+	ok(t, "", "put", db, "users", "two words", "x")
+
+	sh := &shell{c: &cli{}, file: db}
+	sh.cwd = [][]byte{[]byte("users")}
+	tests := map[string][]string{
+		"co":            {"commit ", "count "},
+		"get n":         {"get nick "},
+		"get t":         {`get "two words" `},
+		"put n x":       nil,
+		"cd 4":          {"cd 42/", "cd 43/"},
+		"cd /u":         {"cd /users/"},
+		"cd /users/42/": nil,
+	}
+	for line, want := range tests {
+		if got := sh.complete(line); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %q, want %q", line, got, want)
+		}
+	}
+}
+
+// Ensure that command lines are split into words, and values.
+func TestWords(t *testing.T) {
+	got, err := words(` put  "two words" "\x00" {"a": 1}`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := []string{"put", "two words", "\x00", `{"a":`, "1}"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	cmd, args, err := parseLine(`put "k 1"  {"a": "b c"} `)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := []string{"k 1", `{"a": "b c"}`}; cmd != "put" || !reflect.DeepEqual(args, want) {
+		t.Errorf("got %s %q, want put %q", cmd, args, want)
+	}
+	if _, args, _ := parseLine(`put k "x\ty"`); args[1] != "x\ty" {
+		t.Errorf("got value %q, want quoted value unquoted", args[1])
+	}
+
+	for _, line := range []string{`get "oops`, `get "\q"`} {
+		if _, err := words(line); err == nil {
+			t.Errorf("%s: expected error", line)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package main
+
+import "errors"
+
+// isTerminal reports false, since raw mode isn't supported here: the
+// shell reads whole lines, without tab completion.
+func isTerminal(fd int) bool {
+	return false
+}
+
+// makeRaw is not supported on this platform.
+func makeRaw(fd int) (restore func(), err error) {
+	return nil, errors.New("raw mode not supported")
+}
@@ -0,0 +1,81 @@
+package buckets
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+)
+
+// A Codec converts keys or values to and from the bytes stored in a
+// bucket.  See TypedBucket.
+type Codec interface {
+	// Marshal returns the encoding of `v`.
+	Marshal(v any) ([]byte, error)
+	// Unmarshal decodes `data` into the value pointed to by `v`.
+	// It must not retain `data`, which may only be valid for the
+	// duration of a transaction.
+	Unmarshal(data []byte, v any) error
+}
+
+// These are the built-in codecs.
+var (
+	// JSON encodes values as JSON, via encoding/json.
+	JSON Codec = jsonCodec{}
+
+	// Gob encodes values as gobs, via encoding/gob.  Each value is
+	// encoded on its own, along with its type information.
+	Gob Codec = gobCodec{}
+
+	// Raw stores byte slices and strings as is.  It cannot encode
+	// values of any other type.
+	Raw Codec = rawCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	switch v := v.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, fmt.Errorf("raw codec cannot encode %T", v)
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	switch v := v.(type) {
+	case *[]byte:
+		*v = clone(data)
+		return nil
+	case *string:
+		*v = string(data)
+		return nil
+	}
+	return fmt.Errorf("raw codec cannot decode into %T", v)
+}
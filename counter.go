@@ -0,0 +1,78 @@
+package buckets
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+// Incr increments the counter stored with key `k` by one, returning
+// the new count.  A missing key is treated as a counter at zero.
+func (bk *Bucket) Incr(k []byte) (int64, error) {
+	return bk.IncrBy(k, 1)
+}
+
+// Decr decrements the counter stored with key `k` by one, returning
+// the new count.  A missing key is treated as a counter at zero.
+func (bk *Bucket) Decr(k []byte) (int64, error) {
+	return bk.IncrBy(k, -1)
+}
+
+// IncrBy adds `delta` to the counter stored with key `k`, returning
+// the new count.  A missing key is treated as a counter at zero.
+//
+// Counters are stored as 8-byte big-endian integers (see DecodeCounter).
+// IncrBy returns ErrNotCounter if the existing value is not one.
+func (bk *Bucket) IncrBy(k []byte, delta int64) (n int64, err error) {
+	err = bk.Update(k, func(old []byte) ([]byte, error) {
+		if old != nil && len(old) != 8 {
+			return nil, fmt.Errorf("key %q: %w", k, ErrNotCounter)
+		}
+		n = DecodeCounter(old) + delta
+		return EncodeCounter(n), nil
+	})
+	return n, err
+}
+
+// NextID returns the next value of the bucket's sequence, an
+// autoincrementing integer starting at 1.
+func (bk *Bucket) NextID() (id uint64, err error) {
+	err = bk.update(func(b *bolt.Bucket) error {
+		id, err = b.NextSequence()
+		return err
+	})
+	return id, err
+}
+
+// Append puts value `v` with a key generated from the bucket's sequence
+// (see NextID), returning the key.  Keys generated this way sort in
+// the order the values were appended.
+func (bk *Bucket) Append(v []byte) (key []byte, err error) {
+	err = bk.update(func(b *bolt.Bucket) error {
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		key = make([]byte, 8)
+		binary.BigEndian.PutUint64(key, id)
+		return bk.put(b, key, v)
+	})
+	return key, err
+}
+
+// EncodeCounter encodes `n` as a counter value, viz. an 8-byte
+// big-endian integer.
+func EncodeCounter(n int64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(n))
+	return buf
+}
+
+// DecodeCounter decodes a counter value.  Nil values are decoded as 0.
+func DecodeCounter(buf []byte) int64 {
+	if len(buf) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(buf))
+}
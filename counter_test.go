@@ -0,0 +1,121 @@
+package buckets_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure that concurrent increments are not lost.
+func TestIncr(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	counts, err := bx.New([]byte("counts"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	key := []byte("hits")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := counts.Incr(key); err != nil {
+				t.Error(err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+
+	n, err := counts.IncrBy(key, -5)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if n != 15 {
+		t.Errorf("got %d, want 15", n)
+	}
+
+	if n, _ = counts.Decr([]byte("misses")); n != -1 {
+		t.Errorf("got %d, want -1", n)
+	}
+
+	// Values that aren't counters can't be incremented.
+	counts.Put([]byte("name"), []byte("counts"))
+	if _, err := counts.Incr([]byte("name")); !errors.Is(err, buckets.ErrNotCounter) {
+		t.Errorf("got error %v, want %v", err, buckets.ErrNotCounter)
+	}
+}
+
+// Ensure that appended values get increasing keys.
+func TestAppend(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	events, err := bx.New([]byte("events"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	id, err := events.NextID()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if id != 1 {
+		t.Errorf("got id %d, want 1", id)
+	}
+
+	// Append enough values for the keys to need more than one byte.
+	var keys [][]byte
+	for i := 0; i < 300; i++ {
+		k, err := events.Append([]byte(fmt.Sprintf("event %d", i)))
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		keys = append(keys, k)
+	}
+
+	items, err := events.Items()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(items) != len(keys) {
+		t.Fatalf("got %d items, want %d", len(items), len(keys))
+	}
+	for i, item := range items {
+		if !bytes.Equal(item.Key, keys[i]) {
+			t.Errorf("got key %x, want %x", item.Key, keys[i])
+		}
+		if want := fmt.Sprintf("event %d", i); string(item.Value) != want {
+			t.Errorf("got %s, want %s", item.Value, want)
+		}
+	}
+}
+
+// Show that we can keep counts in a bucket.
+func ExampleBucket_Incr() {
+	bx, _ := buckets.Open(tempfile())
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	hits, _ := bx.New([]byte("hits"))
+
+	for _, path := range []string{"/foo", "/bar", "/foo"} {
+		hits.Incr([]byte(path))
+	}
+
+	hits.Map(func(k, v []byte) error {
+		fmt.Printf("hits to %s: %d\n", k, buckets.DecodeCounter(v))
+		return nil
+	})
+
+	// Output:
+	// hits to /bar: 1
+	// hits to /foo: 2
+}
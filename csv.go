@@ -0,0 +1,371 @@
+package buckets
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/boltdb/bolt"
+)
+
+// CSVExportOptions configure ExportCSV.
+type CSVExportOptions struct {
+	// Columns are the fields of the values, which must be JSON objects,
+	// to write in the columns after the key.  String fields are written
+	// as is and other fields as JSON; missing fields are left empty.  If
+	// Columns is empty, each value is written as is in a single column.
+	Columns []string
+
+	// NoHeader omits the header row, which names the columns: "key",
+	// and then the fields (or "value").
+	NoHeader bool
+}
+
+// ExportCSV writes the bucket's items to `w` as CSV, one row per item,
+// with the key in the first column.  Nested buckets are not included.
+// Since CSV is text, it fails on keys (or values written as is) that
+// aren't UTF-8: use Export for binary data.
+func (bk *Bucket) ExportCSV(w io.Writer, opts CSVExportOptions) error {
+	return exportCSV(w, bk.Map, opts)
+}
+
+// ExportCSV writes the items with keys having the scanner's prefix to
+// `w` as CSV, as in Bucket.ExportCSV.
+func (ps *PrefixScanner) ExportCSV(w io.Writer, opts CSVExportOptions) error {
+	return exportCSV(w, ps.Map, opts)
+}
+
+// ExportCSV writes the items with keys in the scanner's range to `w` as
+// CSV, as in Bucket.ExportCSV.
+func (rs *RangeScanner) ExportCSV(w io.Writer, opts CSVExportOptions) error {
+	return exportCSV(w, rs.Map, opts)
+}
+
+// exportCSV writes the items mapped by `m` to `w` as CSV.
+func exportCSV(w io.Writer, m mapper, opts CSVExportOptions) error {
+	bw := bufio.NewWriter(w)
+	cw := csv.NewWriter(bw)
+	if !opts.NoHeader {
+		header := append([]string{"key"}, opts.Columns...)
+		if len(opts.Columns) == 0 {
+			header = append(header, "value")
+		}
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+	}
+	err := m(func(k, v []byte) error {
+		if v == nil {
+			return nil
+		}
+		row, err := csvRow(k, v, opts.Columns)
+		if err != nil {
+			return fmt.Errorf("item with key %q: %w", k, err)
+		}
+		return cw.Write(row)
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// csvRow returns the CSV row for key `k` and value `v`, with the given
+// fields of the value in the columns after the key.
+func csvRow(k, v []byte, columns []string) ([]string, error) {
+	if !utf8.Valid(k) {
+		return nil, errors.New("key isn't UTF-8")
+	}
+	if len(columns) == 0 {
+		if !utf8.Valid(v) {
+			return nil, errors.New("value isn't UTF-8")
+		}
+		return []string{string(k), string(v)}, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(v, &fields); err != nil {
+		return nil, err
+	}
+	row := []string{string(k)}
+	for _, col := range columns {
+		var s string
+		switch raw := fields[col]; {
+		case raw == nil || string(raw) == "null":
+		case raw[0] == '"':
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return nil, err
+			}
+		default:
+			if !utf8.Valid(raw) {
+				return nil, fmt.Errorf("field %q isn't UTF-8", col)
+			}
+			s = string(raw)
+		}
+		row = append(row, s)
+	}
+	return row, nil
+}
+
+// CSVImportOptions configure ImportCSV.
+type CSVImportOptions struct {
+	// Key is a template for the key of each row, in which the names of
+	// columns in braces are replaced by the row's values, e.g.,
+	// "{day}/{id}".  It's required.
+	Key string
+
+	// Columns are the columns making up the value of each row: a JSON
+	// object mapping the column names to the row's values (as strings).
+	// If empty, every column is included.
+	Columns []string
+
+	// BatchSize is the maximum number of rows put per transaction.
+	// Defaults to 1000.
+	BatchSize int
+
+	// NoOverwrite skips rows whose keys already exist, as with
+	// InsertNX, rather than updating them.
+	NoOverwrite bool
+
+	// DryRun reads and validates every row without importing any
+	// (checking for existing keys if NoOverwrite is set).
+	DryRun bool
+}
+
+// A CSVReport summarizes a CSV import.
+type CSVReport struct {
+	Rows     int        // rows read, not including the header
+	Imported int        // rows put, or that would be in a dry run
+	Errors   []RowError // rows skipped, in order
+}
+
+// A RowError reports a row of CSV input that couldn't be imported.
+type RowError struct {
+	Line int // line number where the row starts
+	Err  error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e RowError) Unwrap() error {
+	return e.Err
+}
+
+// ImportCSV reads rows of CSV from `r`, the first of which names the
+// columns, and puts them in the bucket, with keys and values made up of
+// the columns given in `opts`.
+//
+// Rows that are malformed, leave a column of the key template empty,
+// or repeat the key of an earlier row are skipped and listed in the
+// report, so the rest can be imported; use DryRun to check them all
+// before importing any.  The error returned is for problems with the
+// input as a whole (e.g., a column in `opts` that isn't in the header),
+// or with putting the rows.  The rows are put in batches, as with
+// Import, so if an error occurs, the batches before it have been
+// imported.
+func (bk *Bucket) ImportCSV(r io.Reader, opts CSVImportOptions) (
+	*CSVReport, error) {
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	report := &CSVReport{}
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err == io.EOF {
+		return report, errors.New("missing header")
+	} else if err != nil {
+		return report, fmt.Errorf("header: %w", err)
+	}
+	tmpl, cols, err := csvMapping(header, opts)
+	if err != nil {
+		return report, err
+	}
+
+	var batch []struct{ Key, Value []byte }
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		var n int
+		var err error
+		switch {
+		case !opts.DryRun:
+			n, err = insertSorted(bk, batch, opts.NoOverwrite)
+		case opts.NoOverwrite:
+			n, err = missing(bk, batch)
+		default:
+			n = len(batch)
+		}
+		if err != nil {
+			return err
+		}
+		report.Imported += n
+		batch = batch[:0]
+		return nil
+	}
+	seen := make(map[string]int) // keys to the lines they were read from
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			report.Rows++
+			report.Errors = append(report.Errors, RowError{pe.StartLine, pe.Err})
+			continue
+		} else if err != nil {
+			return report, err
+		}
+		report.Rows++
+		line, _ := cr.FieldPos(0)
+		k, v, err := tmpl.row(row, header, cols)
+		if err == nil {
+			if prev, ok := seen[string(k)]; ok {
+				err = fmt.Errorf("duplicate key %q (see line %d)", k, prev)
+			}
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, RowError{line, err})
+			continue
+		}
+		seen[string(k)] = line
+		batch = append(batch, struct{ Key, Value []byte }{k, v})
+		if len(batch) == opts.BatchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, flush()
+}
+
+// missing returns the number of `items` whose keys aren't in bucket `bk`.
+func missing(bk *Bucket, items []struct{ Key, Value []byte }) (n int,
+	err error) {
+
+	err = bk.view(func(b *bolt.Bucket) error {
+		for _, item := range items {
+			if bk.get(b, item.Key) == nil {
+				n++
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// csvMapping parses the key template in `opts` and looks up the columns
+// in `header` that it and the value are made of.
+func csvMapping(header []string, opts CSVImportOptions) (keyTemplate,
+	[]int, error) {
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		if _, ok := index[name]; ok {
+			return nil, nil, fmt.Errorf("duplicate column %q", name)
+		}
+		index[name] = i
+	}
+	tmpl, err := parseKeyTemplate(opts.Key, index)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cols []int
+	for _, name := range opts.Columns {
+		i, ok := index[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("value column %q not in header", name)
+		}
+		cols = append(cols, i)
+	}
+	if len(opts.Columns) == 0 {
+		for i := range header {
+			cols = append(cols, i)
+		}
+	}
+	return tmpl, cols, nil
+}
+
+// A keyTemplate is a parsed key template: a sequence of literal text
+// and column references.
+type keyTemplate []keyPart
+
+// A keyPart is literal text, if col is -1, or else a column reference.
+type keyPart struct {
+	text string
+	col  int
+}
+
+// parseKeyTemplate parses template `s`, looking up the columns it refers
+// to in `index`.
+func parseKeyTemplate(s string, index map[string]int) (keyTemplate, error) {
+	if s == "" {
+		return nil, errors.New("missing key template")
+	}
+	var tmpl keyTemplate
+	for s != "" {
+		open := strings.IndexAny(s, "{}")
+		if open < 0 {
+			tmpl = append(tmpl, keyPart{s, -1})
+			break
+		}
+		if s[open] == '}' {
+			return nil, errors.New("key template has unmatched }")
+		}
+		if open > 0 {
+			tmpl = append(tmpl, keyPart{s[:open], -1})
+		}
+		end := strings.IndexByte(s[open:], '}')
+		if end < 0 {
+			return nil, errors.New("key template has unmatched {")
+		}
+		name := s[open+1 : open+end]
+		i, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("key column %q not in header", name)
+		}
+		tmpl = append(tmpl, keyPart{name, i})
+		s = s[open+end+1:]
+	}
+	return tmpl, nil
+}
+
+// row returns the key and value for a CSV row, the value being a JSON
+// object of the columns in `cols`, named per `header`.
+func (tmpl keyTemplate) row(row, header []string, cols []int) (k, v []byte,
+	err error) {
+
+	for _, part := range tmpl {
+		if part.col < 0 {
+			k = append(k, part.text...)
+			continue
+		}
+		if row[part.col] == "" {
+			return nil, nil, fmt.Errorf("key column %q is empty", part.text)
+		}
+		k = append(k, row[part.col]...)
+	}
+	if len(k) > bolt.MaxKeySize {
+		return nil, nil, ErrKeyTooLarge
+	}
+	fields := make(map[string]string, len(cols))
+	for _, i := range cols {
+		fields[header[i]] = row[i]
+	}
+	if v, err = json.Marshal(fields); err != nil {
+		return nil, nil, err
+	}
+	return k, v, nil
+}
@@ -0,0 +1,217 @@
+package buckets_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure that we can import rows of CSV with a key template.
+func TestImportCSV(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	visits, _ := bx.New([]byte("visits"))
+	input := `day,id,city,count
+mon,1,Chicago,3
+mon,2,"St. Louis, MO",5
+tue,1,Chicago
+tue,,Denver,1
+mon,1,Chicago,4
+tue,2,Denver,2
+`
+	opts := buckets.CSVImportOptions{
+		Key:     "{day}/{id}",
+		Columns: []string{"city", "count"},
+	}
+
+	// A dry run reports bad rows without importing anything.
+	opts.DryRun = true
+	report, err := visits.ImportCSV(strings.NewReader(input), opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if report.Rows != 6 || report.Imported != 3 {
+		t.Errorf("got %d rows, %d imported, want 6, 3", report.Rows,
+			report.Imported)
+	}
+	var lines []int
+	for _, e := range report.Errors {
+		lines = append(lines, e.Line)
+	}
+	if want := []int{4, 5, 6}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("got errors on lines %v, want %v (%v)", lines, want,
+			report.Errors)
+	}
+	if items, _ := visits.Items(); len(items) != 0 {
+		t.Errorf("got %d items after dry run, want 0", len(items))
+	}
+
+	opts.DryRun = false
+	opts.BatchSize = 2
+	report, err = visits.ImportCSV(strings.NewReader(input), opts)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if report.Imported != 3 || len(report.Errors) != 3 {
+		t.Errorf("got %d imported, %d errors, want 3, 3", report.Imported,
+			len(report.Errors))
+	}
+	want := map[string]string{
+		"mon/1": `{"city":"Chicago","count":"3"}`,
+		"mon/2": `{"city":"St. Louis, MO","count":"5"}`,
+		"tue/2": `{"city":"Denver","count":"2"}`,
+	}
+	items, _ := visits.Items()
+	for _, item := range items {
+		if v := want[string(item.Key)]; string(item.Value) != v {
+			t.Errorf("got %s=%s, want %s", item.Key, item.Value, v)
+		}
+	}
+	if len(items) != len(want) {
+		t.Errorf("got %d items, want %d", len(items), len(want))
+	}
+
+	// Rows with existing keys are skipped with NoOverwrite, and not
+	// counted, whether or not it's a dry run.
+	more := "day,id,city,count\nmon,1,Boston,9\nwed,1,Austin,1\n"
+	opts.NoOverwrite = true
+	for _, dryRun := range []bool{true, false} {
+		opts.DryRun = dryRun
+		report, err = visits.ImportCSV(strings.NewReader(more), opts)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if report.Imported != 1 {
+			t.Errorf("dry run %v: got %d imported, want 1", dryRun,
+				report.Imported)
+		}
+	}
+	if items, _ := visits.Items(); len(items) != 4 {
+		t.Errorf("got %d items, want 4", len(items))
+	}
+
+	// Problems with the mapping fail the whole import.
+	bad := map[string]buckets.CSVImportOptions{
+		"no key":       {},
+		"key column":   {Key: "{day}/{name}"},
+		"unmatched":    {Key: "{day"},
+		"value column": {Key: "{day}", Columns: []string{"name"}},
+	}
+	for name, opts := range bad {
+		if _, err := visits.ImportCSV(strings.NewReader(input), opts); err == nil {
+			t.Errorf("%s: expected import error", name)
+		}
+	}
+}
+
+// Ensure that keys too large for bolt are reported as row errors.
+func TestImportCSVKeyTooLarge(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+	input := "id\n" + strings.Repeat("x", 40000) + "\n"
+	report, err := things.ImportCSV(strings.NewReader(input),
+		buckets.CSVImportOptions{Key: "{id}"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(report.Errors) != 1 ||
+		!errors.Is(report.Errors[0], buckets.ErrKeyTooLarge) {
+		t.Errorf("got errors %v, want key too large", report.Errors)
+	}
+}
+
+// Ensure that scanned items can be exported as CSV.
+func TestExportCSV(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	visits, _ := bx.New([]byte("visits"))
+	visits.Insert([]struct{ Key, Value []byte }{
+		{[]byte("mon/1"), []byte(`{"city":"Chicago","count":3}`)},
+		{[]byte("mon/2"), []byte(`{"city":"St. Louis, MO","tags":["a"]}`)},
+		{[]byte("tue/1"), []byte(`{"city":"Denver","count":null}`)},
+	})
+
+	var buf bytes.Buffer
+	err := visits.NewPrefixScanner([]byte("mon/")).ExportCSV(&buf,
+		buckets.CSVExportOptions{Columns: []string{"city", "count", "tags"}})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := `key,city,count,tags
+mon/1,Chicago,3,
+mon/2,"St. Louis, MO",,"[""a""]"
+`
+	if buf.String() != want {
+		t.Errorf("got %s, want %s", buf.String(), want)
+	}
+
+	buf.Reset()
+	err = visits.NewRangeScanner([]byte("mon/2"), nil).ExportCSV(&buf,
+		buckets.CSVExportOptions{NoHeader: true})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want = `mon/2,"{""city"":""St. Louis, MO"",""tags"":[""a""]}"
+tue/1,"{""city"":""Denver"",""count"":null}"
+`
+	if buf.String() != want {
+		t.Errorf("got %s, want %s", buf.String(), want)
+	}
+
+	// Values must be JSON objects to export fields.
+	visits.Put([]byte("wed/1"), []byte("not json"))
+	err = visits.ExportCSV(&buf, buckets.CSVExportOptions{
+		Columns: []string{"city"},
+	})
+	if err == nil {
+		t.Error("expected error exporting fields of non-JSON value")
+	}
+
+	// CSV is text, so keys must be UTF-8.
+	binary, _ := bx.New([]byte("binary"))
+	binary.Put([]byte{0xff}, []byte("x"))
+	if err := binary.ExportCSV(&buf, buckets.CSVExportOptions{}); err == nil {
+		t.Error("expected error exporting key that isn't UTF-8")
+	}
+}
+
+// Show that we can import a spreadsheet and export it again.
+func ExampleBucket_ImportCSV() {
+	bx, _ := buckets.Open(tempfile())
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	todos, _ := bx.New([]byte("todos"))
+	input := `day,seq,task
+mon,1,milk cows
+mon,2,feed pigs
+tue,,fold laundry
+`
+	report, _ := todos.ImportCSV(strings.NewReader(input),
+		buckets.CSVImportOptions{
+			Key:     "{day}/{seq}",
+			Columns: []string{"task"},
+		})
+	for _, err := range report.Errors {
+		fmt.Println(err)
+	}
+	todos.ExportCSV(os.Stdout, buckets.CSVExportOptions{
+		Columns: []string{"task"},
+	})
+
+	// Output:
+	// line 4: key column "seq" is empty
+	// key,task
+	// mon/1,milk cows
+	// mon/2,feed pigs
+}
@@ -1,8 +1,12 @@
 package buckets_test
 
 import (
+	"bytes"
+	"errors"
 	"os"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/joyrexus/buckets"
 )
@@ -16,3 +20,258 @@ func TestOpen(t *testing.T) {
 	defer os.Remove(bx.Path())
 	defer bx.Close()
 }
+
+// Ensure we can open a database with options.
+func TestOpenWithOptions(t *testing.T) {
+	path := tempfile()
+	defer os.Remove(path)
+
+	bx, err := buckets.OpenWithOptions(path, &buckets.Options{
+		Timeout:  time.Second,
+		FileMode: 0640,
+		NoSync:   true,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !bx.NoSync {
+		t.Error("expected NoSync to be set")
+	}
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if err := things.Put([]byte("A"), []byte("alpha")); err != nil {
+		t.Error(err.Error())
+	}
+	bx.Close()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if mode := info.Mode().Perm(); mode != 0640 {
+		t.Errorf("got file mode %v, want %v", mode, os.FileMode(0640))
+	}
+
+	// Several read-only handles can share the file.
+	options := &buckets.Options{ReadOnly: true, Timeout: time.Second}
+	first, err := buckets.OpenWithOptions(path, options)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer first.Close()
+	second, err := buckets.OpenWithOptions(path, options)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer second.Close()
+
+	if _, err := second.New([]byte("things")); err == nil {
+		t.Error("expected error creating bucket in read-only database")
+	}
+
+	err = second.ReadTx(func(tx *buckets.Tx) error {
+		v, err := tx.Bucket(things).Get([]byte("A"))
+		if err != nil {
+			return err
+		}
+		if want := []byte("alpha"); !bytes.Equal(v, want) {
+			t.Errorf("got %s, want %s", v, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+}
+
+// Ensure that opening a locked database is retried.
+func TestOpenRetry(t *testing.T) {
+	bx := NewTestDB()
+	path := bx.Path()
+
+	// Release the lock once the first attempt has timed out, before
+	// the second.
+	var retries []int
+	again, err := buckets.OpenWithOptions(path, &buckets.Options{
+		Timeout: 10 * time.Millisecond,
+		Retry: buckets.RetryPolicy{
+			Attempts: 5,
+			Delay:    time.Millisecond,
+			OnRetry: func(retry int, err error) {
+				if !errors.Is(err, buckets.ErrTimeout) {
+					t.Errorf("got error %v, want %v", err, buckets.ErrTimeout)
+				}
+				retries = append(retries, retry)
+				bx.DB.Close()
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(again.Path())
+	defer again.Close()
+	if want := []int{1}; !reflect.DeepEqual(retries, want) {
+		t.Errorf("got retries %v, want %v", retries, want)
+	}
+
+	// Without retries, the error is returned.
+	_, err = buckets.OpenWithOptions(path, &buckets.Options{
+		Timeout: 10 * time.Millisecond,
+	})
+	if !errors.Is(err, buckets.ErrTimeout) {
+		t.Errorf("got error %v, want %v", err, buckets.ErrTimeout)
+	}
+}
+
+// Ensure we can list the buckets in a database along with their stats.
+func TestBuckets(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	start := time.Now()
+
+	names := []string{"letters", "numbers"}
+	for _, name := range names {
+		bk, err := bx.New([]byte(name))
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if err := bk.Put([]byte("A"), []byte("1")); err != nil {
+			t.Error(err.Error())
+		}
+	}
+	numbers, _ := bx.New([]byte("numbers"))
+	numbers.Put([]byte("B"), []byte("2"))
+
+	infos, err := bx.Buckets()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(infos) != len(names) {
+		t.Fatalf("got %d buckets, want %d", len(infos), len(names))
+	}
+
+	for i, want := range []int{1, 2} {
+		info := infos[i]
+		if got := string(info.Bucket.Name); got != names[i] {
+			t.Errorf("got bucket %s, want %s", got, names[i])
+		}
+		if info.KeyN != want {
+			t.Errorf("%s: got %d keys, want %d", info.Bucket.Name, info.KeyN, want)
+		}
+		if info.Depth != 1 {
+			t.Errorf("%s: got depth %d, want 1", info.Bucket.Name, info.Depth)
+		}
+		if info.Created.Before(start.Add(-time.Second)) {
+			t.Errorf("%s: unexpected creation time %v", info.Bucket.Name, info.Created)
+		}
+	}
+
+	// Nested buckets report their own info too.
+	nested, err := numbers.New([]byte("odd"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	info, err := nested.Info()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if info.Created.IsZero() {
+		t.Error("expected creation time for nested bucket")
+	}
+}
+
+// Ensure we can open an existing bucket without creating it.
+func TestDBBucket(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	if _, err := bx.Bucket([]byte("things")); !errors.Is(err, buckets.ErrBucketNotFound) {
+		t.Errorf("got error %v, want %v", err, buckets.ErrBucketNotFound)
+	}
+
+	if _, err := bx.New([]byte("things")); err != nil {
+		t.Error(err.Error())
+	}
+
+	things, err := bx.Bucket([]byte("things"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := things.Put([]byte("A"), []byte("alpha")); err != nil {
+		t.Error(err.Error())
+	}
+}
+
+// Ensure that the buckets used internally can't be opened, created or
+// deleted.
+func TestReservedNames(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	bx.New([]byte("things")) // records its creation in the meta bucket
+	meta := []byte("\x00buckets/meta")
+	attempts := map[string]func() error{
+		"New":        func() error { _, err := bx.New(meta); return err },
+		"NewPath":    func() error { _, err := bx.NewPath(meta, []byte("x")); return err },
+		"Delete":     func() error { return bx.Delete(meta) },
+		"Bucket":     func() error { _, err := bx.Bucket(meta); return err },
+		"BucketPath": func() error { _, err := bx.BucketPath(meta); return err },
+		"Tx.New": func() error {
+			return bx.Tx(func(tx *buckets.Tx) error {
+				_, err := tx.New(meta)
+				return err
+			})
+		},
+		"Tx.BucketPath": func() error {
+			return bx.ReadTx(func(tx *buckets.Tx) error {
+				_, err := tx.BucketPath(meta)
+				return err
+			})
+		},
+	}
+	for name, attempt := range attempts {
+		if err := attempt(); !errors.Is(err, buckets.ErrReservedName) {
+			t.Errorf("%s: got error %v, want %v", name, err,
+				buckets.ErrReservedName)
+		}
+	}
+	infos, _ := bx.Buckets()
+	if len(infos) != 1 {
+		t.Errorf("got %d buckets, want 1", len(infos))
+	}
+	if info, _ := infos[0].Bucket.Info(); info.Created.IsZero() {
+		t.Error("got zero creation time; meta bucket was changed")
+	}
+}
+
+// Ensure we can open an existing nested bucket without creating it.
+func TestDBBucketPath(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	path := [][]byte{[]byte("users"), []byte("42"), []byte("sessions")}
+	if _, err := bx.BucketPath(path...); !errors.Is(err, buckets.ErrBucketNotFound) {
+		t.Errorf("got error %v, want %v", err, buckets.ErrBucketNotFound)
+	}
+	if _, err := bx.BucketPath(); err == nil {
+		t.Error("expected error for empty path")
+	}
+
+	if _, err := bx.NewPath(path...); err != nil {
+		t.Fatal(err.Error())
+	}
+	sessions, err := bx.BucketPath(path...)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := sessions.Put([]byte("s1"), []byte("x")); err != nil {
+		t.Error(err.Error())
+	}
+	if got := bytes.Join(sessions.Path(), []byte("/")); string(got) != "users/42/sessions" {
+		t.Errorf("got path %s, want users/42/sessions", got)
+	}
+}
@@ -4,7 +4,7 @@ Package buckets provides a simplified interface to a Bolt database.
 
 A buckets DB is a Bolt database, but it allows you to easily create new bucket instances.  The database is represented by a single file on disk.  A bucket is a collection of unique keys that are associated with values.
 
-The Bucket type has nifty convenience methods for operating on key/value pairs within it.  It streamlines simple transactions (a single put, get, or delete) and working with subsets of items within a bucket (via prefix and range scans).  It's not designed to handle more complex or batch transactions. For such cases, use the standard techniques offered by Bolt.
+The Bucket type has nifty convenience methods for operating on key/value pairs within it.  It streamlines simple transactions (a single put, get, or delete) and working with subsets of items within a bucket (via prefix and range scans).  To group several operations (possibly across buckets) into a single transaction, use DB.Tx or DB.ReadTx, which hand you bucket handles bound to the transaction.  For anything more involved, use the standard techniques offered by Bolt.
 
 ---
 
@@ -0,0 +1,58 @@
+package buckets
+
+import (
+	"errors"
+
+	"github.com/boltdb/bolt"
+)
+
+// These errors can be returned when operating on buckets and the items
+// within them.  Use errors.Is to check for them, since they are usually
+// wrapped with more context (e.g., the name of the bucket).
+//
+// Most are bolt's own errors, so checking for either works.
+var (
+	// ErrBucketNotFound is returned when operating on a bucket that
+	// does not exist, e.g., one removed via DB.Delete.
+	ErrBucketNotFound = bolt.ErrBucketNotFound
+
+	// ErrKeyNotFound is returned when getting a key that does not exist.
+	ErrKeyNotFound = errors.New("key not found")
+
+	// ErrNotCounter is returned when incrementing a key whose value
+	// is not a counter.
+	ErrNotCounter = errors.New("value is not a counter")
+
+	// ErrEmptyKey is returned when putting or getting an empty key.
+	ErrEmptyKey = bolt.ErrKeyRequired
+
+	// ErrKeyTooLarge is returned when putting a key larger than
+	// bolt.MaxKeySize.
+	ErrKeyTooLarge = bolt.ErrKeyTooLarge
+
+	// ErrValueTooLarge is returned when putting a value larger than
+	// bolt.MaxValueSize.
+	ErrValueTooLarge = bolt.ErrValueTooLarge
+
+	// ErrStopIteration can be returned by a function passed to Map (or
+	// MapPrefix, MapRange, &c.) to stop the iteration early.  The Map
+	// method then returns nil rather than the error.
+	ErrStopIteration = errors.New("stop iteration")
+
+	// ErrReservedName is returned when opening, creating or deleting
+	// a top-level bucket with a name reserved for internal use: one
+	// starting with "\x00buckets/".
+	ErrReservedName = errors.New("reserved bucket name")
+
+	// ErrIndexNotFound is returned when using an index that has not
+	// been registered with Bucket.AddIndex.
+	ErrIndexNotFound = errors.New("index not found")
+
+	// ErrLogTruncated is returned when reading entries of the change
+	// log that have already been deleted.
+	ErrLogTruncated = errors.New("change log truncated")
+
+	// ErrTimeout is returned by Open when it cannot obtain a lock on
+	// the database file in time.
+	ErrTimeout = bolt.ErrTimeout
+)
@@ -0,0 +1,120 @@
+package buckets_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure that operating on a deleted bucket returns ErrBucketNotFound
+// rather than panicking.
+func TestBucketNotFound(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if err := bx.Delete([]byte("things")); err != nil {
+		t.Error(err.Error())
+	}
+
+	k, v := []byte("A"), []byte("alpha")
+	do := func(k, v []byte) error { return nil }
+
+	checks := map[string]error{
+		"Put":       things.Put(k, v),
+		"PutNX":     things.PutNX(k, v),
+		"Delete":    things.Delete(k),
+		"Map":       things.Map(do),
+		"MapPrefix": things.MapPrefix(do, k),
+		"MapRange":  things.MapRange(do, k, k),
+	}
+	_, checks["Get"] = things.Get(k)
+	_, checks["Items"] = things.Items()
+	_, checks["PrefixItems"] = things.PrefixItems(k)
+	_, checks["RangeItems"] = things.RangeItems(k, k)
+	_, checks["New"] = things.New([]byte("nested"))
+
+	scanners := map[string]buckets.Scanner{
+		"PrefixScanner": things.NewPrefixScanner(k),
+		"RangeScanner":  things.NewRangeScanner(k, k),
+	}
+	for name, s := range scanners {
+		checks[name+".Map"] = s.Map(do)
+		_, checks[name+".Count"] = s.Count()
+		_, checks[name+".Keys"] = s.Keys()
+		_, checks[name+".Values"] = s.Values()
+		_, checks[name+".Items"] = s.Items()
+		_, checks[name+".ItemMapping"] = s.ItemMapping()
+	}
+
+	for method, err := range checks {
+		if !errors.Is(err, buckets.ErrBucketNotFound) {
+			t.Errorf("%s: got error %v, want %v",
+				method, err, buckets.ErrBucketNotFound)
+		}
+	}
+
+	// Deleting the bucket again reports that it's missing too.
+	err = bx.Delete([]byte("things"))
+	if !errors.Is(err, buckets.ErrBucketNotFound) {
+		t.Errorf("got error %v, want %v", err, buckets.ErrBucketNotFound)
+	}
+}
+
+// Ensure that invalid keys are reported.
+func TestInvalidKeys(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, err := bx.New([]byte("things"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	empty := []byte{}
+	if err := things.Put(empty, []byte("x")); !errors.Is(err, buckets.ErrEmptyKey) {
+		t.Errorf("Put: got error %v, want %v", err, buckets.ErrEmptyKey)
+	}
+	if _, err := things.Get(empty); !errors.Is(err, buckets.ErrEmptyKey) {
+		t.Errorf("Get: got error %v, want %v", err, buckets.ErrEmptyKey)
+	}
+	if err := things.Delete(nil); !errors.Is(err, buckets.ErrEmptyKey) {
+		t.Errorf("Delete: got error %v, want %v", err, buckets.ErrEmptyKey)
+	}
+
+	// Insert stops at the first invalid item.
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("alpha")},
+		{empty, []byte("nothing")},
+	}
+	if err := things.Insert(items); !errors.Is(err, buckets.ErrEmptyKey) {
+		t.Errorf("Insert: got error %v, want %v", err, buckets.ErrEmptyKey)
+	}
+	if _, err := things.Get([]byte("A")); !errors.Is(err, buckets.ErrKeyNotFound) {
+		t.Errorf("Insert should have been rolled back: got error %v", err)
+	}
+
+	big := bytes.Repeat([]byte("k"), bolt.MaxKeySize+1)
+	if err := things.Put(big, []byte("x")); !errors.Is(err, buckets.ErrKeyTooLarge) {
+		t.Errorf("Put: got error %v, want %v", err, buckets.ErrKeyTooLarge)
+	}
+}
+
+// Ensure that Open reports a lock timeout when the database is in use.
+func TestOpenTimeout(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	_, err := buckets.Open(bx.Path())
+	if !errors.Is(err, buckets.ErrTimeout) {
+		t.Errorf("got error %v, want %v", err, buckets.ErrTimeout)
+	}
+}
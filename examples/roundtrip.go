@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -125,8 +126,13 @@ type Service struct {
 func (s *Service) get(w http.ResponseWriter, r *http.Request, _ mux.Params) {
 	key := []byte(r.URL.String())
 	value, err := s.todos.Get(key)
+	if errors.Is(err, buckets.ErrKeyNotFound) {
+		http.NotFound(w, r)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), 500)
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(value)
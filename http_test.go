@@ -1,7 +1,6 @@
 package buckets_test
 
 import (
-	"encoding/binary"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -26,11 +25,9 @@ type counter struct {
 func (c counter) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	key := []byte(req.URL.String())
 
-	// Decode handles key not found for us.
-	value, _ := c.hits.Get(key)
-	count := decode(value) + 1
-
-	if err := c.hits.Put(key, encode(count)); err != nil {
+	// Increment the count in a single transaction.
+	count, err := c.hits.Incr(key)
+	if err != nil {
 		http.Error(rw, err.Error(), 500)
 		return
 	}
@@ -98,7 +95,7 @@ func ExampleBucket() {
 
 	// Check the final result
 	do := func(k, v []byte) error {
-		fmt.Printf("hits to %s: %d\n", k, decode(v))
+		fmt.Printf("hits to %s: %d\n", k, buckets.DecodeCounter(v))
 		return nil
 	}
 	hits.Map(do)
@@ -118,18 +115,3 @@ func ExampleBucket() {
 	// hits to /thud: 10
 	// hits to /xyzzy: 10
 }
-
-// encode marshals a counter.
-func encode(n uint64) []byte {
-	buf := make([]byte, 8)
-	binary.BigEndian.PutUint64(buf, n)
-	return buf
-}
-
-// decode unmarshals a counter. Nil buffers are decoded as 0.
-func decode(buf []byte) uint64 {
-	if buf == nil {
-		return 0
-	}
-	return binary.BigEndian.Uint64(buf)
-}
@@ -0,0 +1,293 @@
+package buckets
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+	"github.com/joyrexus/buckets/keyenc"
+)
+
+// indexBucket holds the entries of secondary indexes.  It contains a
+// bucket for each bucket with indexes (named by the bucket's pathKey),
+// which in turn contains a bucket of entries for each index.  The key of
+// each entry is a keyenc.Tuple of an index key and a primary key, so the
+// entries are ordered by index key, then by primary key.
+var indexBucket = []byte("\x00buckets/index")
+
+// An IndexFunc returns the index keys for the item with key `k` and
+// value `v`, i.e., the keys under which the item can be found in the
+// index.  It may return any number of keys, including none.
+//
+// It must not retain or modify `k` or `v`.
+type IndexFunc func(k, v []byte) [][]byte
+
+// index is an index function registered for a bucket.
+type index struct {
+	name []byte
+	fn   IndexFunc
+}
+
+// An Index is a secondary index of a bucket's items, mapping index keys
+// (computed from the items by an IndexFunc) to the items.
+//
+// Lookups only return items that currently exist (and have not expired),
+// copied as with Bucket.Items.
+type Index struct {
+	bk   *Bucket
+	Name []byte
+}
+
+// AddIndex registers index `name` for the bucket, with `fn` computing
+// the index keys of each item, and returns the index.  From then on,
+// every write to the bucket (Put, Insert, Delete, &c.) updates the
+// index in the same transaction.  Registering an index again with the
+// same name replaces its IndexFunc.
+//
+// Index functions are not saved in the database: register them each
+// time the database is opened, before writing to the bucket.  If items
+// were written while the index was not registered (or with a different
+// IndexFunc), call Rebuild to bring the index up to date.
+func (bk *Bucket) AddIndex(name []byte, fn IndexFunc) *Index {
+	key := string(pathKey(bk.Path()))
+	db := bk.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.indexes == nil {
+		db.indexes = make(map[string][]index)
+	}
+	// Copy the slice, since writers may be reading the old one.
+	var indexes []index
+	for _, ix := range db.indexes[key] {
+		if !bytes.Equal(ix.name, name) {
+			indexes = append(indexes, ix)
+		}
+	}
+	db.indexes[key] = append(indexes, index{clone(name), fn})
+	return bk.Index(name)
+}
+
+// Index returns the bucket's index `name`, which must be registered
+// with AddIndex before use.
+func (bk *Bucket) Index(name []byte) *Index {
+	return &Index{bk: bk, Name: name}
+}
+
+// DropIndex unregisters the bucket's index `name` and deletes its
+// entries.
+func (bk *Bucket) DropIndex(name []byte) error {
+	key := string(pathKey(bk.Path()))
+	db := bk.db
+	db.mu.Lock()
+	var indexes []index
+	for _, ix := range db.indexes[key] {
+		if !bytes.Equal(ix.name, name) {
+			indexes = append(indexes, ix)
+		}
+	}
+	if db.indexes != nil {
+		db.indexes[key] = indexes
+	}
+	db.mu.Unlock()
+
+	return bk.update(func(b *bolt.Bucket) error {
+		return dropIndex(b.Tx(), bk.Path(), name)
+	})
+}
+
+// LookupByIndex returns the items whose keys in index `name` include
+// `key`.  It's shorthand for bk.Index(name).Lookup(key).
+func (bk *Bucket) LookupByIndex(name, key []byte) ([]Item, error) {
+	return bk.Index(name).Lookup(key)
+}
+
+// Lookup returns the items whose index keys include `key`, ordered by
+// their (primary) keys.
+func (ix *Index) Lookup(key []byte) ([]Item, error) {
+	return ix.items(keyenc.Tuple(key), func(ikey []byte) bool {
+		return bytes.Equal(ikey, key)
+	})
+}
+
+// PrefixItems returns the items with index keys having prefix `pre`,
+// ordered by index key.  An item is returned once for each matching
+// index key.
+func (ix *Index) PrefixItems(pre []byte) ([]Item, error) {
+	return ix.items(keyenc.Tuple(pre), func(ikey []byte) bool {
+		return bytes.HasPrefix(ikey, pre)
+	})
+}
+
+// RangeItems returns the items with index keys within the range from
+// `min` to `max`, ordered by index key.  The bounds are as for
+// Bucket.RangeItems.  An item is returned once for each matching index
+// key.
+func (ix *Index) RangeItems(min, max []byte) ([]Item, error) {
+	var seek []byte
+	if min != nil {
+		seek = keyenc.Tuple(min)
+	}
+	return ix.items(seek, func(ikey []byte) bool {
+		return isBefore(ikey, max)
+	})
+}
+
+// Rebuild deletes the index's entries and indexes every item in the
+// bucket again.
+func (ix *Index) Rebuild() error {
+	def, err := ix.registered()
+	if err != nil {
+		return err
+	}
+	path := ix.bk.Path()
+	return ix.bk.update(func(b *bolt.Bucket) error {
+		tx := b.Tx()
+		if err := dropIndex(tx, path, ix.Name); err != nil {
+			return err
+		}
+		return b.ForEach(func(k, v []byte) error {
+			if v == nil {
+				return nil
+			}
+			return reindex(tx, path, []index{def}, k, nil, v)
+		})
+	})
+}
+
+// registered returns the registered index function for ix.
+func (ix *Index) registered() (index, error) {
+	for _, def := range ix.bk.db.indexesOf(ix.bk.Path()) {
+		if bytes.Equal(def.name, ix.Name) {
+			return def, nil
+		}
+	}
+	return index{}, fmt.Errorf("index %q: %w", ix.Name, ErrIndexNotFound)
+}
+
+// items returns copies of the items for the index entries from `seek`
+// onwards, while `in` holds for their index keys.
+func (ix *Index) items(seek []byte, in func(ikey []byte) bool) (items []Item,
+	err error) {
+
+	if _, err := ix.registered(); err != nil {
+		return nil, err
+	}
+	err = ix.bk.view(func(b *bolt.Bucket) error {
+		entries := indexEntries(b.Tx(), ix.bk.Path(), ix.Name)
+		if entries == nil {
+			return nil
+		}
+		c := entries.Cursor()
+		for e, _ := c.Seek(seek); e != nil; e, _ = c.Next() {
+			ikey, k, err := splitEntry(e)
+			if err != nil {
+				return err
+			}
+			if !in(ikey) {
+				break
+			}
+			if v := ix.bk.get(b, k); v != nil {
+				items = append(items, Item{clone(k), clone(v)})
+			}
+		}
+		return nil
+	})
+	return items, err
+}
+
+// indexesOf returns the indexes registered for the bucket at `path`.
+// The returned slice must not be modified.
+func (db *DB) indexesOf(path [][]byte) []index {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.indexes == nil {
+		return nil
+	}
+	return db.indexes[string(pathKey(path))]
+}
+
+// indexEntries returns the bucket of entries for index `name` of the
+// bucket at `path`, or nil if there are none.
+func indexEntries(tx *bolt.Tx, path [][]byte, name []byte) *bolt.Bucket {
+	root := tx.Bucket(indexBucket)
+	if root == nil {
+		return nil
+	}
+	b := root.Bucket(pathKey(path))
+	if b == nil {
+		return nil
+	}
+	return b.Bucket(name)
+}
+
+// createIndexEntries creates/opens the bucket of entries for index `name`
+// of the bucket at `path`.
+func createIndexEntries(tx *bolt.Tx, path [][]byte,
+	name []byte) (*bolt.Bucket, error) {
+
+	root, err := tx.CreateBucketIfNotExists(indexBucket)
+	if err != nil {
+		return nil, err
+	}
+	b, err := root.CreateBucketIfNotExists(pathKey(path))
+	if err != nil {
+		return nil, err
+	}
+	return b.CreateBucketIfNotExists(name)
+}
+
+// dropIndex deletes the entries for index `name` of the bucket at `path`.
+func dropIndex(tx *bolt.Tx, path [][]byte, name []byte) error {
+	root := tx.Bucket(indexBucket)
+	if root == nil {
+		return nil
+	}
+	b := root.Bucket(pathKey(path))
+	if b == nil || b.Bucket(name) == nil {
+		return nil
+	}
+	return b.DeleteBucket(name)
+}
+
+// reindex updates the entries of `indexes` for key `k` of the bucket at
+// `path`, whose value changed from `old` to `v`.  Either value is nil if
+// the key did not or does not exist.
+func reindex(tx *bolt.Tx, path [][]byte, indexes []index, k, old,
+	v []byte) error {
+
+	for _, ix := range indexes {
+		entries, err := createIndexEntries(tx, path, ix.name)
+		if err != nil {
+			return err
+		}
+		if old != nil {
+			for _, ikey := range ix.fn(k, old) {
+				if err := entries.Delete(keyenc.Tuple(ikey, k)); err != nil {
+					return err
+				}
+			}
+		}
+		if v != nil {
+			for _, ikey := range ix.fn(k, v) {
+				if err := entries.Put(keyenc.Tuple(ikey, k), []byte{}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// splitEntry splits the key of an index entry into its index key and
+// primary key.
+func splitEntry(e []byte) (ikey, k []byte, err error) {
+	elems, err := keyenc.DecodeTuple(e)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(elems) != 2 {
+		return nil, nil, fmt.Errorf("index entry of %d elements: %w",
+			len(elems), keyenc.ErrMalformed)
+	}
+	return elems[0], elems[1], nil
+}
@@ -0,0 +1,235 @@
+package buckets_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+type user struct {
+	Email string
+	City  string
+	Tags  []string
+}
+
+// byCity indexes json-encoded users by city.
+func byCity(k, v []byte) [][]byte {
+	var u user
+	if err := json.Unmarshal(v, &u); err != nil || u.City == "" {
+		return nil
+	}
+	return [][]byte{[]byte(u.City)}
+}
+
+// byTag indexes json-encoded users by each of their tags.
+func byTag(k, v []byte) (keys [][]byte) {
+	var u user
+	json.Unmarshal(v, &u)
+	for _, tag := range u.Tags {
+		keys = append(keys, []byte(tag))
+	}
+	return keys
+}
+
+// putUser puts a json-encoded user into bucket `bk`.
+func putUser(t *testing.T, bk *buckets.Bucket, id string, u user) {
+	v, _ := json.Marshal(u)
+	if err := bk.Put([]byte(id), v); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+// lookup returns the keys of the items in index `ix` with index key `key`.
+func lookup(t *testing.T, ix *buckets.Index, key string) []string {
+	items, err := ix.Lookup([]byte(key))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return keyStrings(items)
+}
+
+// Ensure that indexes are kept up to date as items are written.
+func TestIndex(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	users, _ := bx.New([]byte("users"))
+	cities := users.AddIndex([]byte("city"), byCity)
+	tags := users.AddIndex([]byte("tag"), byTag)
+
+	putUser(t, users, "1", user{"a@x.com", "Chicago", []string{"admin"}})
+	putUser(t, users, "2", user{"b@x.com", "Boston", []string{"admin", "dev"}})
+	putUser(t, users, "3", user{"c@x.com", "Chicago", []string{"dev"}})
+
+	if got, want := lookup(t, cities, "Chicago"), []string{"1", "3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := lookup(t, tags, "dev"), []string{"2", "3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Moving a user updates the index.
+	putUser(t, users, "1", user{"a@x.com", "Boston", nil})
+	if got, want := lookup(t, cities, "Chicago"), []string{"3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := lookup(t, tags, "admin"), []string{"2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// So does deleting one.
+	if err := users.Delete([]byte("2")); err != nil {
+		t.Error(err.Error())
+	}
+	items, err := users.LookupByIndex([]byte("city"), []byte("Boston"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if got, want := keyStrings(items), []string{"1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Inserts and updates go through the index as well.
+	v, _ := json.Marshal(user{City: "Chicago"})
+	w, _ := json.Marshal(user{City: "Chicago", Tags: []string{"dev"}})
+	err = users.Insert([]struct{ Key, Value []byte }{
+		{[]byte("4"), v},
+		{[]byte("5"), w},
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+	err = users.Update([]byte("3"), func(old []byte) ([]byte, error) {
+		return json.Marshal(user{City: "Boston"})
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if got, want := lookup(t, cities, "Chicago"), []string{"4", "5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := lookup(t, tags, "dev"), []string{"5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// Ensure that index entries written in a transaction are rolled back
+// along with the items.
+func TestIndexRollback(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	users, _ := bx.New([]byte("users"))
+	cities := users.AddIndex([]byte("city"), byCity)
+	putUser(t, users, "1", user{City: "Chicago"})
+
+	oops := errors.New("oops")
+	err := bx.Tx(func(tx *buckets.Tx) error {
+		bk := tx.Bucket(users)
+		putUser(t, bk, "1", user{City: "Boston"})
+		putUser(t, bk, "2", user{City: "Boston"})
+		got, err := bk.LookupByIndex([]byte("city"), []byte("Boston"))
+		if err != nil {
+			return err
+		}
+		if len(got) != 2 {
+			t.Errorf("got %d items within transaction, want 2", len(got))
+		}
+		return oops
+	})
+	if err != oops {
+		t.Errorf("got error %v, want %v", err, oops)
+	}
+
+	if got := lookup(t, cities, "Boston"); len(got) != 0 {
+		t.Errorf("got %v, want none", got)
+	}
+	if got, want := lookup(t, cities, "Chicago"), []string{"1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// Ensure we can scan indexes by prefix and range, and rebuild them.
+func TestIndexScans(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	// Put some users before there is an index.
+	users, _ := bx.New([]byte("users"))
+	putUser(t, users, "1", user{City: "Boston"})
+	putUser(t, users, "2", user{City: "Chicago"})
+	putUser(t, users, "3", user{City: "Berlin"})
+	putUser(t, users, "4", user{City: "Bern"})
+	putUser(t, users, "5", user{City: "Austin"})
+
+	cities := users.AddIndex([]byte("city"), byCity)
+	if got := lookup(t, cities, "Boston"); len(got) != 0 {
+		t.Errorf("got %v before rebuild, want none", got)
+	}
+	if err := cities.Rebuild(); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	items, err := cities.PrefixItems([]byte("Ber"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if got, want := keyStrings(items), []string{"3", "4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	items, err = cities.RangeItems([]byte("B"), []byte("Boston"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if got, want := keyStrings(items), []string{"3", "4", "1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	items, err = cities.RangeItems(nil, []byte("Berlin"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if got, want := keyStrings(items), []string{"5", "3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if err := users.DropIndex([]byte("city")); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := cities.Lookup([]byte("Boston")); !errors.Is(err, buckets.ErrIndexNotFound) {
+		t.Errorf("got error %v, want %v", err, buckets.ErrIndexNotFound)
+	}
+}
+
+// Show that we can look up items by an index on their values.
+func ExampleBucket_AddIndex() {
+	bx, _ := buckets.Open(tempfile())
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	// Index people by the first letter of their name.
+	people, _ := bx.New([]byte("people"))
+	people.AddIndex([]byte("initial"), func(k, v []byte) [][]byte {
+		return [][]byte{bytes.ToUpper(v[:1])}
+	})
+
+	people.Put([]byte("1"), []byte("alice"))
+	people.Put([]byte("2"), []byte("bob"))
+	people.Put([]byte("3"), []byte("anna"))
+
+	items, _ := people.LookupByIndex([]byte("initial"), []byte("A"))
+	for _, item := range items {
+		fmt.Printf("%s: %s\n", item.Key, item.Value)
+	}
+
+	// Output:
+	// 1: alice
+	// 3: anna
+}
@@ -0,0 +1,162 @@
+package buckets
+
+import "iter"
+
+// All returns an iterator over the bucket's key/value pairs, for use
+// with range-over-func:
+//
+//	for k, v := range bk.All() {
+//		...
+//	}
+//
+// The read transaction is held open while iterating and closed when
+// the loop ends (or breaks), so don't write to the database from
+// within the loop.  Keys and values are copies, safe to retain.  As
+// with Items, nested buckets are skipped.
+// If the bucket cannot be read (e.g., it does not exist), the sequence
+// is empty: use AllErr to detect such errors.
+func (bk *Bucket) All() iter.Seq2[[]byte, []byte] {
+	return seqItems(bk.Map)
+}
+
+// AllErr is like All, but reports errors: it returns an iterator over
+// the bucket's items, each with a nil error, ending with the error if
+// the bucket cannot be read (e.g., ErrBucketNotFound):
+//
+//	for item, err := range bk.AllErr() {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (bk *Bucket) AllErr() iter.Seq2[Item, error] {
+	return seqErr(bk.Map)
+}
+
+// AllKeys returns an iterator over the bucket's keys.  See All.
+func (bk *Bucket) AllKeys() iter.Seq[[]byte] {
+	return seqKeys(bk.Map)
+}
+
+// AllValues returns an iterator over the bucket's values.  See All.
+func (bk *Bucket) AllValues() iter.Seq[[]byte] {
+	return seqValues(bk.Map)
+}
+
+// All returns an iterator over the key/value pairs for keys with
+// prefix.  See Bucket.All.
+func (ps *PrefixScanner) All() iter.Seq2[[]byte, []byte] {
+	return seqItems(ps.Map)
+}
+
+// AllErr returns an iterator over the items for keys with prefix,
+// reporting errors.  See Bucket.AllErr.
+func (ps *PrefixScanner) AllErr() iter.Seq2[Item, error] {
+	return seqErr(ps.Map)
+}
+
+// AllKeys returns an iterator over the keys with prefix.
+// See Bucket.All.
+func (ps *PrefixScanner) AllKeys() iter.Seq[[]byte] {
+	return seqKeys(ps.Map)
+}
+
+// AllValues returns an iterator over the values for keys with prefix.
+// See Bucket.All.
+func (ps *PrefixScanner) AllValues() iter.Seq[[]byte] {
+	return seqValues(ps.Map)
+}
+
+// All returns an iterator over the key/value pairs for keys within
+// the range.  See Bucket.All.
+func (rs *RangeScanner) All() iter.Seq2[[]byte, []byte] {
+	return seqItems(rs.Map)
+}
+
+// AllErr returns an iterator over the items for keys within the
+// range, reporting errors.  See Bucket.AllErr.
+func (rs *RangeScanner) AllErr() iter.Seq2[Item, error] {
+	return seqErr(rs.Map)
+}
+
+// AllKeys returns an iterator over the keys within the range.
+// See Bucket.All.
+func (rs *RangeScanner) AllKeys() iter.Seq[[]byte] {
+	return seqKeys(rs.Map)
+}
+
+// AllValues returns an iterator over the values for keys within
+// the range.  See Bucket.All.
+func (rs *RangeScanner) AllValues() iter.Seq[[]byte] {
+	return seqValues(rs.Map)
+}
+
+// A mapper applies a func to a set of key/value pairs, e.g., Bucket.Map.
+type mapper func(do func(k, v []byte) error) error
+
+// seqItems returns an iterator over copies of the k/v pairs of `m`,
+// skipping nested buckets, as do the other seq funcs.
+func seqItems(m mapper) iter.Seq2[[]byte, []byte] {
+	return func(yield func(k, v []byte) bool) {
+		m(func(k, v []byte) error {
+			if v == nil {
+				return nil
+			}
+			if !yield(clone(k), clone(v)) {
+				return ErrStopIteration
+			}
+			return nil
+		})
+	}
+}
+
+// seqErr returns an iterator over copies of the items of `m`, ending
+// with the error returned by `m`, if any.
+func seqErr(m mapper) iter.Seq2[Item, error] {
+	return func(yield func(Item, error) bool) {
+		done := false
+		err := m(func(k, v []byte) error {
+			if v == nil {
+				return nil
+			}
+			if !yield(Item{clone(k), clone(v)}, nil) {
+				done = true
+				return ErrStopIteration
+			}
+			return nil
+		})
+		if err != nil && !done {
+			yield(Item{}, err)
+		}
+	}
+}
+
+// seqKeys returns an iterator over copies of the keys of `m`.
+func seqKeys(m mapper) iter.Seq[[]byte] {
+	return func(yield func(k []byte) bool) {
+		m(func(k, v []byte) error {
+			if v == nil {
+				return nil
+			}
+			if !yield(clone(k)) {
+				return ErrStopIteration
+			}
+			return nil
+		})
+	}
+}
+
+// seqValues returns an iterator over copies of the values of `m`.
+func seqValues(m mapper) iter.Seq[[]byte] {
+	return func(yield func(v []byte) bool) {
+		m(func(_, v []byte) error {
+			if v == nil {
+				return nil
+			}
+			if !yield(clone(v)) {
+				return ErrStopIteration
+			}
+			return nil
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package buckets_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure we can range over buckets and scanners.
+func TestIterators(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("1")},
+		{[]byte("AA"), []byte("2")},
+		{[]byte("AB"), []byte("3")},
+		{[]byte("B"), []byte("4")},
+		{[]byte("C"), []byte("5")},
+	}
+	if err := things.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+	things.New([]byte("AAA")) // nested buckets are skipped, as by Items
+
+	var got []string
+	for k, v := range things.All() {
+		got = append(got, string(k)+"="+string(v))
+	}
+	want := []string{"A=1", "AA=2", "AB=3", "B=4", "C=5"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	scanners := map[string]buckets.Scanner{
+		"prefix": things.NewPrefixScanner([]byte("A")),
+		"range":  things.NewRangeScanner([]byte("A"), []byte("AB")),
+	}
+	for name, s := range scanners {
+		var keys, values []string
+		for k := range s.AllKeys() {
+			keys = append(keys, string(k))
+		}
+		for v := range s.AllValues() {
+			values = append(values, string(v))
+		}
+		if want := []string{"A", "AA", "AB"}; !reflect.DeepEqual(keys, want) {
+			t.Errorf("%s: got keys %v, want %v", name, keys, want)
+		}
+		if want := []string{"1", "2", "3"}; !reflect.DeepEqual(values, want) {
+			t.Errorf("%s: got values %v, want %v", name, values, want)
+		}
+	}
+
+	// Breaking out of the loop closes the read transaction, so we can
+	// write again afterwards.
+	for k := range things.AllKeys() {
+		if string(k) == "AA" {
+			break
+		}
+	}
+	if err := things.Put([]byte("D"), []byte("6")); err != nil {
+		t.Error(err.Error())
+	}
+	n := 0
+	for range things.AllValues() {
+		n++
+	}
+	if n != 6 {
+		t.Errorf("got %d values, want 6", n)
+	}
+}
+
+// Ensure that AllErr reports errors reading a bucket.
+func TestIteratorErrors(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+	things.Put([]byte("A"), []byte("1"))
+	things.Put([]byte("B"), []byte("2"))
+
+	var got []string
+	for item, err := range things.AllErr() {
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		got = append(got, string(item.Key)+"="+string(item.Value))
+	}
+	if want := []string{"A=1", "B=2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for range things.NewPrefixScanner([]byte("A")).AllErr() {
+		break // stopping early is not an error
+	}
+
+	bx.Delete([]byte("things"))
+	var errs []error
+	for _, err := range things.NewRangeScanner(nil, nil).AllErr() {
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], buckets.ErrBucketNotFound) {
+		t.Errorf("got errors %v, want ErrBucketNotFound", errs)
+	}
+}
+
+// Show that we can range over the items in a bucket.
+func ExampleBucket_All() {
+	bx, _ := buckets.Open(tempfile())
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	letters, _ := bx.New([]byte("letters"))
+	letters.Put([]byte("A"), []byte("alpha"))
+	letters.Put([]byte("B"), []byte("beta"))
+	letters.Put([]byte("C"), []byte("gamma"))
+
+	for k, v := range letters.All() {
+		if string(k) == "C" {
+			break
+		}
+		fmt.Printf("%s -> %s\n", k, v)
+	}
+
+	// Output:
+	// A -> alpha
+	// B -> beta
+}
@@ -0,0 +1,68 @@
+package keyenc
+
+import (
+	"fmt"
+	"time"
+)
+
+// Codec encodes keys of type int, int64, uint, uint64, float64,
+// time.Time, string and []byte with the matching encoder of this
+// package.  It satisfies the buckets.Codec interface, so it can be used
+// as the key codec of a TypedBucket:
+//
+//	events := buckets.NewTypedBucket[time.Time, Event](bk, keyenc.Codec, buckets.JSON)
+var Codec codec
+
+type codec struct{}
+
+// Marshal returns the order-preserving encoding of `v`.
+func (codec) Marshal(v any) ([]byte, error) {
+	switch v := v.(type) {
+	case int:
+		return Int64(int64(v)), nil
+	case int64:
+		return Int64(v), nil
+	case uint:
+		return Uint64(uint64(v)), nil
+	case uint64:
+		return Uint64(v), nil
+	case float64:
+		return Float64(v), nil
+	case time.Time:
+		return Time(v), nil
+	case string:
+		return String(v), nil
+	case []byte:
+		return v, nil
+	}
+	return nil, fmt.Errorf("keyenc cannot encode %T", v)
+}
+
+// Unmarshal decodes `data` into the value pointed to by `v`.
+func (codec) Unmarshal(data []byte, v any) (err error) {
+	switch v := v.(type) {
+	case *int:
+		var n int64
+		n, err = DecodeInt64(data)
+		*v = int(n)
+	case *int64:
+		*v, err = DecodeInt64(data)
+	case *uint:
+		var n uint64
+		n, err = DecodeUint64(data)
+		*v = uint(n)
+	case *uint64:
+		*v, err = DecodeUint64(data)
+	case *float64:
+		*v, err = DecodeFloat64(data)
+	case *time.Time:
+		*v, err = DecodeTime(data)
+	case *string:
+		*v = DecodeString(data)
+	case *[]byte:
+		*v = append([]byte(nil), data...)
+	default:
+		return fmt.Errorf("keyenc cannot decode into %T", v)
+	}
+	return err
+}
@@ -0,0 +1,188 @@
+/*
+Package keyenc encodes values as keys whose byte order matches the
+natural order of the values, so that they can be scanned with prefix and
+range scans.
+
+	min, max := keyenc.Int64(-10), keyenc.Int64(10)
+	items, err := bucket.NewRangeScanner(min, max).Items()
+
+Composite keys are built with Tuple, which escapes and terminates each
+element so that tuples sort element by element:
+
+	key := keyenc.Tuple(keyenc.String("users"), keyenc.Int64(42))
+	sessions := bucket.NewPrefixScanner(key)
+
+Each encoding has a matching decoder.  The Codec value can be used as a
+key codec for a buckets.TypedBucket.
+*/
+package keyenc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrMalformed is returned when decoding a key that was not produced
+// by the matching encoder.
+var ErrMalformed = errors.New("malformed key")
+
+// Uint64 encodes `n` as 8 big-endian bytes.
+func Uint64(n uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, n)
+	return b
+}
+
+// DecodeUint64 decodes a key encoded with Uint64.
+func DecodeUint64(b []byte) (uint64, error) {
+	if len(b) != 8 {
+		return 0, fmt.Errorf("uint64 of %d bytes: %w", len(b), ErrMalformed)
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// Int64 encodes `n` as 8 big-endian bytes with the sign bit flipped,
+// so that negative numbers sort before positive ones.
+func Int64(n int64) []byte {
+	return Uint64(uint64(n) ^ 1<<63)
+}
+
+// DecodeInt64 decodes a key encoded with Int64.
+func DecodeInt64(b []byte) (int64, error) {
+	u, err := DecodeUint64(b)
+	if err != nil {
+		return 0, err
+	}
+	return int64(u ^ 1<<63), nil
+}
+
+// Float64 encodes `f` as 8 bytes that sort in numeric order.  Positive
+// numbers have their sign bit flipped; negative numbers have all their
+// bits flipped.  NaNs sort after +Inf (or before -Inf, if negative).
+func Float64(f float64) []byte {
+	u := math.Float64bits(f)
+	if u&(1<<63) != 0 {
+		u = ^u
+	} else {
+		u ^= 1 << 63
+	}
+	return Uint64(u)
+}
+
+// DecodeFloat64 decodes a key encoded with Float64.
+func DecodeFloat64(b []byte) (float64, error) {
+	u, err := DecodeUint64(b)
+	if err != nil {
+		return 0, err
+	}
+	if u&(1<<63) != 0 {
+		u ^= 1 << 63
+	} else {
+		u = ^u
+	}
+	return math.Float64frombits(u), nil
+}
+
+// Time encodes `t` as 12 bytes: its Unix time in seconds (as in Int64)
+// followed by the nanoseconds within the second.  The location and
+// monotonic clock reading are not encoded.
+func Time(t time.Time) []byte {
+	b := make([]byte, 12)
+	copy(b, Int64(t.Unix()))
+	binary.BigEndian.PutUint32(b[8:], uint32(t.Nanosecond()))
+	return b
+}
+
+// DecodeTime decodes a key encoded with Time.  The time is in UTC.
+func DecodeTime(b []byte) (time.Time, error) {
+	if len(b) != 12 {
+		return time.Time{}, fmt.Errorf("time of %d bytes: %w", len(b),
+			ErrMalformed)
+	}
+	sec, _ := DecodeInt64(b[:8])
+	nsec := binary.BigEndian.Uint32(b[8:])
+	if nsec >= 1e9 {
+		return time.Time{}, fmt.Errorf("time with %d ns: %w", nsec,
+			ErrMalformed)
+	}
+	return time.Unix(sec, int64(nsec)).UTC(), nil
+}
+
+// String encodes `s` as its bytes, which already sort in order.
+func String(s string) []byte {
+	return []byte(s)
+}
+
+// DecodeString decodes a key encoded with String.
+func DecodeString(b []byte) string {
+	return string(b)
+}
+
+// Tuple elements are terminated by a 0x00 0x01 pair, and any 0x00
+// within an element is escaped as 0x00 0xff.  Since the terminator sorts
+// before every escaped or unescaped byte, a tuple sorts before any tuple
+// extending it, and elements are compared one at a time.
+const (
+	escape     = 0x00
+	terminator = 0x01
+	escaped    = 0xff
+)
+
+// Tuple encodes a composite key from its already encoded elements
+// (e.g., Tuple(String("users"), Int64(42))).  The keys of tuples sort
+// element by element.
+//
+// Since each element is terminated, the encoding of a tuple is a prefix
+// of the encoding of any tuple that extends it, so a Tuple can be used
+// as the prefix of a prefix scan.
+func Tuple(elems ...[]byte) []byte {
+	var buf bytes.Buffer
+	for _, elem := range elems {
+		for _, c := range elem {
+			buf.WriteByte(c)
+			if c == escape {
+				buf.WriteByte(escaped)
+			}
+		}
+		buf.WriteByte(escape)
+		buf.WriteByte(terminator)
+	}
+	return buf.Bytes()
+}
+
+// DecodeTuple splits a key encoded with Tuple into its elements,
+// which can then be decoded individually.
+func DecodeTuple(b []byte) ([][]byte, error) {
+	var elems [][]byte
+	var elem []byte
+	for i := 0; i < len(b); i++ {
+		if b[i] != escape {
+			elem = append(elem, b[i])
+			continue
+		}
+		if i++; i == len(b) {
+			return nil, fmt.Errorf("tuple ends with escape: %w", ErrMalformed)
+		}
+		switch b[i] {
+		case escaped:
+			elem = append(elem, escape)
+		case terminator:
+			if elem == nil {
+				elem = []byte{}
+			}
+			elems = append(elems, elem)
+			elem = nil
+		default:
+			return nil, fmt.Errorf("tuple escape 0x%02x at byte %d: %w",
+				b[i], i, ErrMalformed)
+		}
+	}
+	if elem != nil {
+		return nil, fmt.Errorf("unterminated tuple element: %w", ErrMalformed)
+	}
+	return elems, nil
+}
@@ -0,0 +1,227 @@
+package keyenc_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/joyrexus/buckets"
+	"github.com/joyrexus/buckets/keyenc"
+)
+
+// isSorted checks that the encoded keys sort in the given order.
+func isSorted(keys [][]byte) bool {
+	for i := 1; i < len(keys); i++ {
+		if bytes.Compare(keys[i-1], keys[i]) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Ensure that integers encode in order and round-trip.
+func TestInt64(t *testing.T) {
+	nums := []int64{math.MinInt64, -1 << 40, -256, -1, 0, 1, 255, 1 << 40,
+		math.MaxInt64}
+	var keys [][]byte
+	for _, n := range nums {
+		key := keyenc.Int64(n)
+		keys = append(keys, key)
+		got, err := keyenc.DecodeInt64(key)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if got != n {
+			t.Errorf("got %d, want %d", got, n)
+		}
+	}
+	if !isSorted(keys) {
+		t.Errorf("int64 keys out of order: %x", keys)
+	}
+
+	if _, err := keyenc.DecodeInt64([]byte{1, 2}); !errors.Is(err, keyenc.ErrMalformed) {
+		t.Errorf("got error %v, want %v", err, keyenc.ErrMalformed)
+	}
+}
+
+// Ensure that unsigned integers encode in order and round-trip.
+func TestUint64(t *testing.T) {
+	nums := []uint64{0, 1, 255, 256, 1 << 40, math.MaxUint64}
+	var keys [][]byte
+	for _, n := range nums {
+		key := keyenc.Uint64(n)
+		keys = append(keys, key)
+		if got, _ := keyenc.DecodeUint64(key); got != n {
+			t.Errorf("got %d, want %d", got, n)
+		}
+	}
+	if !isSorted(keys) {
+		t.Errorf("uint64 keys out of order: %x", keys)
+	}
+}
+
+// Ensure that floats encode in order and round-trip.
+func TestFloat64(t *testing.T) {
+	nums := []float64{math.Inf(-1), -math.MaxFloat64, -1e10, -1.5,
+		-math.SmallestNonzeroFloat64, 0, math.SmallestNonzeroFloat64, 0.25,
+		1, 1e10, math.MaxFloat64, math.Inf(1)}
+	var keys [][]byte
+	for _, f := range nums {
+		key := keyenc.Float64(f)
+		keys = append(keys, key)
+		got, err := keyenc.DecodeFloat64(key)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if got != f {
+			t.Errorf("got %g, want %g", got, f)
+		}
+	}
+	if !isSorted(keys) {
+		t.Errorf("float64 keys out of order: %x", keys)
+	}
+}
+
+// Ensure that times encode in order and round-trip.
+func TestTime(t *testing.T) {
+	times := []time.Time{
+		time.Date(1066, 10, 14, 9, 0, 0, 0, time.UTC),
+		time.Date(1969, 12, 31, 23, 59, 59, 999999999, time.UTC),
+		time.Unix(0, 0),
+		time.Unix(0, 1),
+		time.Date(2016, 1, 1, 12, 0, 0, 0, time.FixedZone("EST", -5*3600)),
+		time.Date(2300, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	var keys [][]byte
+	for _, tm := range times {
+		key := keyenc.Time(tm)
+		keys = append(keys, key)
+		got, err := keyenc.DecodeTime(key)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if !got.Equal(tm) {
+			t.Errorf("got %v, want %v", got, tm)
+		}
+	}
+	if !isSorted(keys) {
+		t.Errorf("time keys out of order: %x", keys)
+	}
+}
+
+// Ensure that tuples sort element by element and round-trip.
+func TestTuple(t *testing.T) {
+	s := keyenc.String
+	tuples := [][][]byte{
+		{s("a")},
+		{s("a"), s("")},
+		{s("a"), s("b")},
+		{s("a"), keyenc.Int64(-1)},
+		{s("a"), keyenc.Int64(0)},
+		{s("a\x00")},
+		{s("a\x00"), s("b")},
+		{s("a\x01")},
+		{s("ab")},
+		{s("b")},
+	}
+	var keys [][]byte
+	for _, elems := range tuples {
+		key := keyenc.Tuple(elems...)
+		keys = append(keys, key)
+		got, err := keyenc.DecodeTuple(key)
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if !reflect.DeepEqual(got, elems) {
+			t.Errorf("got %q, want %q", got, elems)
+		}
+	}
+	if !isSorted(keys) {
+		t.Errorf("tuple keys out of order: %q", keys)
+	}
+
+	for _, bad := range []string{"a", "a\x00", "a\x00\x02"} {
+		if _, err := keyenc.DecodeTuple([]byte(bad)); !errors.Is(err, keyenc.ErrMalformed) {
+			t.Errorf("%q: got error %v, want %v", bad, err, keyenc.ErrMalformed)
+		}
+	}
+}
+
+// Ensure that encoded keys work with range and prefix scans, and with
+// typed buckets.
+func TestScans(t *testing.T) {
+	bx, err := buckets.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer bx.Close()
+
+	temps, _ := bx.New([]byte("temps"))
+	readings := buckets.NewTypedBucket[float64, string](temps, keyenc.Codec,
+		buckets.Raw)
+	for _, f := range []float64{-20.5, -3, 0, 4.25, 18, 31} {
+		if err := readings.Put(f, fmt.Sprint(f)); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	items, err := readings.RangeItems(-5, 5)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	var got []float64
+	for _, item := range items {
+		got = append(got, item.Key)
+	}
+	if want := []float64{-3, 0, 4.25}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	users, _ := bx.New([]byte("users"))
+	for _, id := range []int64{-1, 7, 42, 300} {
+		for _, session := range []string{"a", "b"} {
+			key := keyenc.Tuple(keyenc.String("user"), keyenc.Int64(id),
+				keyenc.String(session))
+			users.Put(key, []byte(session))
+		}
+	}
+	pre := keyenc.Tuple(keyenc.String("user"), keyenc.Int64(42))
+	count, err := users.NewPrefixScanner(pre).Count()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if count != 2 {
+		t.Errorf("got %d sessions for user 42, want 2", count)
+	}
+}
+
+// Show that we can scan a range of signed integer keys.
+func Example() {
+	dir, _ := os.MkdirTemp("", "keyenc-")
+	defer os.RemoveAll(dir)
+	bx, _ := buckets.Open(filepath.Join(dir, "example.db"))
+	defer bx.Close()
+
+	balances, _ := bx.New([]byte("balances"))
+	for _, n := range []int64{-100, -5, 0, 5, 100} {
+		balances.Put(keyenc.Int64(n), []byte(fmt.Sprint(n)))
+	}
+
+	// Negative numbers sort before positive ones.
+	rs := balances.NewRangeScanner(keyenc.Int64(-10), keyenc.Int64(10))
+	for k := range rs.AllKeys() {
+		n, _ := keyenc.DecodeInt64(k)
+		fmt.Println(n)
+	}
+
+	// Output:
+	// -5
+	// 0
+	// 5
+}
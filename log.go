@@ -0,0 +1,319 @@
+package buckets
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/joyrexus/buckets/keyenc"
+)
+
+// logBucket holds the change log.  It contains an `entries` bucket
+// mapping sequence numbers (8 bytes, big-endian) to encoded log entries,
+// an `offsets` bucket mapping consumer names to the sequence numbers
+// they have committed, and the retention policy under `retention`.
+// Every write is logged while it exists, whatever options the database
+// was opened with.
+var logBucket = []byte("\x00buckets/log")
+
+var (
+	logEntries   = []byte("entries")
+	logOffsets   = []byte("offsets")
+	logRetention = []byte("retention")
+)
+
+// LogRetention limits the number of entries kept in the change log.
+// Older entries are deleted as new ones are added.  The zero value
+// keeps every entry.
+type LogRetention struct {
+	// MaxEntries is the maximum number of entries to keep.
+	MaxEntries int
+
+	// MaxAge is the maximum age of the entries to keep.
+	MaxAge time.Duration
+}
+
+// A LogEntry records a change made to a key.
+type LogEntry struct {
+	Seq  uint64    // sequence number, increasing with each change
+	Time time.Time // when the change was made, per the database's clock
+	Event
+}
+
+// ReadLog returns up to `limit` entries of the change log with sequence
+// numbers after `after`, in order.  Pass 0 to read from the start.
+// It returns ErrLogTruncated if entries after `after` have already been
+// deleted (per the retention policy, or by TruncateLog), so that readers
+// don't miss changes unawares; use LogRange to find the entries left.
+//
+// The change log records every put and delete made to the database's
+// buckets once it has been enabled by opening the database with
+// Options.ChangeLog, including the deletion of expired keys by Sweep.
+// Each entry is written in the same transaction as the change it
+// records, so the log is consistent with the data.  (Creating and
+// deleting buckets is not logged.)
+func (db *DB) ReadLog(after uint64, limit int) (entries []LogEntry,
+	err error) {
+
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid limit: %d", limit)
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		b, _ := changeLog(tx)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		first := b.Sequence() + 1
+		if k, _ := c.First(); k != nil {
+			first = binary.BigEndian.Uint64(k)
+		}
+		if after+1 < first {
+			return fmt.Errorf("entries %d to %d: %w", after+1, first-1,
+				ErrLogTruncated)
+		}
+		k, v := c.Seek(keyenc.Uint64(after))
+		if k != nil && binary.BigEndian.Uint64(k) == after {
+			k, v = c.Next()
+		}
+		for ; k != nil && len(entries) < limit; k, v = c.Next() {
+			entry, err := decodeEntry(k, v)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// LogRange returns the sequence numbers of the first and last entries
+// in the change log.  They're both 0 if no changes have been logged;
+// `first` is greater than `last` if every entry has been truncated.
+func (db *DB) LogRange() (first, last uint64, err error) {
+	err = db.View(func(tx *bolt.Tx) error {
+		b, _ := changeLog(tx)
+		if b == nil {
+			return nil
+		}
+		last = b.Sequence()
+		first = last + 1
+		if k, _ := b.Cursor().First(); k != nil {
+			first = binary.BigEndian.Uint64(k)
+		}
+		return nil
+	})
+	return first, last, err
+}
+
+// TruncateLog deletes the entries of the change log with sequence
+// numbers before `before`, returning how many were deleted.  E.g., pass
+// the lowest offset committed by your consumers.
+func (db *DB) TruncateLog(before uint64) (n int, err error) {
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, _ := changeLog(tx)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.First() {
+			if binary.BigEndian.Uint64(k) >= before {
+				break
+			}
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
+
+// A Consumer reads the change log, keeping track of its position with
+// an offset saved in the database: the sequence number of the last
+// entry it has processed.
+type Consumer struct {
+	db   *DB
+	Name string
+}
+
+// Consumer returns the named consumer of the change log.
+func (db *DB) Consumer(name string) *Consumer {
+	return &Consumer{db: db, Name: name}
+}
+
+// Offset returns the consumer's committed offset, or 0 if it has not
+// committed one.
+func (c *Consumer) Offset() (offset uint64, err error) {
+	err = c.db.View(func(tx *bolt.Tx) error {
+		_, offsets := changeLog(tx)
+		if offsets == nil {
+			return nil
+		}
+		if v := offsets.Get([]byte(c.Name)); v != nil {
+			offset = binary.BigEndian.Uint64(v)
+		}
+		return nil
+	})
+	return offset, err
+}
+
+// Read returns up to `limit` entries after the consumer's committed
+// offset.  Call Commit once they have been processed.  As with ReadLog,
+// it returns ErrLogTruncated if the consumer has fallen behind the
+// retention policy; commit an offset within LogRange to skip ahead.
+func (c *Consumer) Read(limit int) ([]LogEntry, error) {
+	offset, err := c.Offset()
+	if err != nil {
+		return nil, err
+	}
+	return c.db.ReadLog(offset, limit)
+}
+
+// Commit saves `offset` as the consumer's offset, i.e., the sequence
+// number of the last entry it has processed.
+func (c *Consumer) Commit(offset uint64) error {
+	return c.db.Update(func(tx *bolt.Tx) error {
+		_, offsets, err := createChangeLog(tx)
+		if err != nil {
+			return err
+		}
+		return offsets.Put([]byte(c.Name), keyenc.Uint64(offset))
+	})
+}
+
+// changeLog returns the buckets holding the change log entries and the
+// consumer offsets, or nils if they don't exist.
+func changeLog(tx *bolt.Tx) (entries, offsets *bolt.Bucket) {
+	root := tx.Bucket(logBucket)
+	if root == nil {
+		return nil, nil
+	}
+	return root.Bucket(logEntries), root.Bucket(logOffsets)
+}
+
+// enableChangeLog creates the change log, if need be, and saves the
+// retention policy `r` for it.
+func enableChangeLog(tx *bolt.Tx, r LogRetention) error {
+	if _, _, err := createChangeLog(tx); err != nil {
+		return err
+	}
+	buf := append(keyenc.Uint64(uint64(r.MaxEntries)),
+		keyenc.Uint64(uint64(r.MaxAge))...)
+	return tx.Bucket(logBucket).Put(logRetention, buf)
+}
+
+// retention returns the change log's retention policy.
+func retention(tx *bolt.Tx) (r LogRetention) {
+	root := tx.Bucket(logBucket)
+	if root == nil {
+		return r
+	}
+	if buf := root.Get(logRetention); len(buf) == 16 {
+		r.MaxEntries = int(binary.BigEndian.Uint64(buf[:8]))
+		r.MaxAge = time.Duration(binary.BigEndian.Uint64(buf[8:]))
+	}
+	return r
+}
+
+// createChangeLog creates/opens the buckets holding the change log.
+func createChangeLog(tx *bolt.Tx) (entries, offsets *bolt.Bucket,
+	err error) {
+
+	root, err := tx.CreateBucketIfNotExists(logBucket)
+	if err != nil {
+		return nil, nil, err
+	}
+	if entries, err = root.CreateBucketIfNotExists(logEntries); err != nil {
+		return nil, nil, err
+	}
+	offsets, err = root.CreateBucketIfNotExists(logOffsets)
+	return entries, offsets, err
+}
+
+// appendLog adds an entry for the change of key `k` in the bucket at
+// `path`, from `old` to `v`, to the change log, and then truncates the
+// log according to the retention policy.  The log must be enabled.
+func (db *DB) appendLog(tx *bolt.Tx, path [][]byte, k, old, v []byte) error {
+	if old == nil && v == nil {
+		return nil
+	}
+	entries, _, err := createChangeLog(tx)
+	if err != nil {
+		return err
+	}
+	seq, err := entries.NextSequence()
+	if err != nil {
+		return err
+	}
+	now := db.now()
+	ev := Event{EventPut, path, k, old, v}
+	if v == nil {
+		ev.Type = EventDelete
+	}
+	if err := entries.Put(keyenc.Uint64(seq), encodeEntry(now, ev)); err != nil {
+		return err
+	}
+
+	r := retention(tx)
+	c := entries.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.First() {
+		tooMany := r.MaxEntries > 0 && seq-binary.BigEndian.Uint64(k) >=
+			uint64(r.MaxEntries)
+		tooOld := r.MaxAge > 0 && now.Sub(decodeTime(v[:8])) > r.MaxAge
+		if !tooMany && !tooOld {
+			break
+		}
+		if err := c.Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeEntry encodes a log entry: the time, the event type, and then
+// the event's path (see pathKey), key, old and new values, each
+// prefixed with its length plus one, or 0 if nil.
+func encodeEntry(t time.Time, ev Event) []byte {
+	buf := append(encodeTime(t), byte(ev.Type))
+	for _, field := range [][]byte{pathKey(ev.Path), ev.Key, ev.Old, ev.New} {
+		if field == nil {
+			buf = binary.AppendUvarint(buf, 0)
+			continue
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(field))+1)
+		buf = append(buf, field...)
+	}
+	return buf
+}
+
+// decodeEntry decodes the log entry with key `k` and value `v`,
+// copying its fields.
+func decodeEntry(k, v []byte) (LogEntry, error) {
+	seq := binary.BigEndian.Uint64(k)
+	malformed := fmt.Errorf("malformed log entry %d", seq)
+	if len(v) < 9 {
+		return LogEntry{}, malformed
+	}
+	entry := LogEntry{Seq: seq, Time: decodeTime(v[:8])}
+	entry.Type = EventType(v[8])
+	buf := v[9:]
+	var fields [4][]byte
+	for i := range fields {
+		n, size := binary.Uvarint(buf)
+		if size <= 0 || n > 0 && uint64(len(buf)-size) < n-1 {
+			return LogEntry{}, malformed
+		}
+		buf = buf[size:]
+		if n > 0 {
+			fields[i] = clone(buf[:n-1])
+			buf = buf[n-1:]
+		}
+	}
+	entry.Path = decodePath(fields[0])
+	entry.Key, entry.Old, entry.New = fields[1], fields[2], fields[3]
+	return entry, nil
+}
@@ -0,0 +1,247 @@
+package buckets_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/joyrexus/buckets"
+)
+
+// openWithLog opens a database with a change log and a fake clock.
+func openWithLog(t *testing.T, retention buckets.LogRetention) (*TestDB,
+	*clock) {
+
+	clk := &clock{now: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)}
+	bx, err := buckets.OpenWithOptions(tempfile(), &buckets.Options{
+		Timeout:      time.Second,
+		Clock:        clk.Now,
+		ChangeLog:    true,
+		LogRetention: retention,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return &TestDB{bx}, clk
+}
+
+// describe summarizes log entries as "seq:type:path/key=value".
+func describe(entries []buckets.LogEntry) (s []string) {
+	for _, e := range entries {
+		path := ""
+		for _, name := range e.Path {
+			path += string(name) + "/"
+		}
+		s = append(s, fmt.Sprintf("%d:%s:%s%s=%s", e.Seq, e.Type, path,
+			e.Key, e.New))
+	}
+	return s
+}
+
+// Ensure that writes are recorded in the change log, in order.
+func TestChangeLog(t *testing.T) {
+	bx, clk := openWithLog(t, buckets.LogRetention{})
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+	nested, _ := things.New([]byte("nested"))
+	things.Put([]byte("a"), []byte("1"))
+	things.Insert([]struct{ Key, Value []byte }{
+		{[]byte("b"), []byte("2")},
+		{[]byte("c"), []byte("3")},
+	})
+	nested.Put([]byte("x"), []byte("9"))
+	things.Delete([]byte("a"))
+	things.Delete([]byte("zzz")) // not there, so not logged
+
+	// Expired keys swept are logged as deleted.
+	things.PutWithTTL([]byte("e"), []byte("5"), time.Minute)
+	clk.Advance(time.Minute)
+	if _, err := bx.Sweep(10); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// Rolled back writes are not logged.
+	bx.Tx(func(tx *buckets.Tx) error {
+		tx.Bucket(things).Put([]byte("d"), []byte("4"))
+		return errors.New("oops")
+	})
+
+	entries, err := bx.ReadLog(0, 10)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := []string{
+		"1:put:things/a=1",
+		"2:put:things/b=2",
+		"3:put:things/c=3",
+		"4:put:things/nested/x=9",
+		"5:delete:things/a=",
+		"6:put:things/e=5",
+		"7:delete:things/e=",
+	}
+	if got := describe(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if old := entries[4].Old; string(old) != "1" {
+		t.Errorf("got old value %q for delete, want 1", old)
+	}
+	if old := entries[6].Old; string(old) != "5" {
+		t.Errorf("got old value %q for sweep, want 5", old)
+	}
+
+	entries, err = bx.ReadLog(3, 1)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if got := describe(entries); !reflect.DeepEqual(got, want[3:4]) {
+		t.Errorf("got %v, want %v", got, want[3:4])
+	}
+
+	n, err := bx.TruncateLog(3)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if n != 2 {
+		t.Errorf("got %d truncated entries, want 2", n)
+	}
+	first, last, err := bx.LogRange()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if first != 3 || last != 7 {
+		t.Errorf("got log range %d-%d, want 3-7", first, last)
+	}
+}
+
+// Ensure that consumers can pick up where they left off.
+func TestChangeLogConsumer(t *testing.T) {
+	bx, _ := openWithLog(t, buckets.LogRetention{})
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+	for _, k := range []string{"a", "b", "c"} {
+		things.Put([]byte(k), []byte(k))
+	}
+
+	consumer := bx.Consumer("indexer")
+	entries, err := consumer.Read(2)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if err := consumer.Commit(entries[1].Seq); err != nil {
+		t.Error(err.Error())
+	}
+
+	// Another consumer has its own offset.
+	if offset, _ := bx.Consumer("mailer").Offset(); offset != 0 {
+		t.Errorf("got offset %d, want 0", offset)
+	}
+
+	things.Put([]byte("d"), []byte("d"))
+	entries, err = bx.Consumer("indexer").Read(10)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := []string{"3:put:things/c=c", "4:put:things/d=d"}
+	if got := describe(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// Ensure that the change log is truncated per the retention policy.
+func TestChangeLogRetention(t *testing.T) {
+	bx, clk := openWithLog(t, buckets.LogRetention{
+		MaxEntries: 3,
+		MaxAge:     time.Hour,
+	})
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		things.Put([]byte(k), []byte(k))
+		clk.Advance(time.Minute)
+	}
+	first, last, _ := bx.LogRange()
+	if first != 3 || last != 5 {
+		t.Errorf("got log range %d-%d, want 3-5", first, last)
+	}
+
+	// Entries older than an hour are dropped on the next write.
+	clk.Advance(time.Hour - 90*time.Second)
+	things.Put([]byte("f"), []byte("f"))
+	entries, _ := bx.ReadLog(4, 10)
+	want := []string{"5:put:things/e=e", "6:put:things/f=f"}
+	if got := describe(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// Reading from before the first entry left reports the gap.
+	if _, err := bx.ReadLog(3, 10); !errors.Is(err, buckets.ErrLogTruncated) {
+		t.Errorf("got error %v, want ErrLogTruncated", err)
+	}
+	if _, err := bx.Consumer("late").Read(10); !errors.Is(err, buckets.ErrLogTruncated) {
+		t.Errorf("got error %v for consumer, want ErrLogTruncated", err)
+	}
+}
+
+// Ensure that once the change log is enabled, writes are logged (and
+// the log truncated) even when the database is opened without it.
+func TestChangeLogPersists(t *testing.T) {
+	bx, _ := openWithLog(t, buckets.LogRetention{MaxEntries: 2})
+	path := bx.Path()
+	defer os.Remove(path)
+	things, _ := bx.New([]byte("things"))
+	things.Put([]byte("a"), []byte("a"))
+	bx.DB.Close()
+
+	bx2, err := buckets.Open(path)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer bx2.Close()
+	things, _ = bx2.New([]byte("things"))
+	things.Put([]byte("b"), []byte("b"))
+	things.Put([]byte("c"), []byte("c"))
+
+	entries, err := bx2.ReadLog(1, 10)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	want := []string{"2:put:things/b=b", "3:put:things/c=c"}
+	if got := describe(entries); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// Show that we can read the changes made to a database.
+func ExampleDB_ReadLog() {
+	path := tempfile()
+	defer os.Remove(path)
+	bx, _ := buckets.OpenWithOptions(path, &buckets.Options{
+		Timeout:   time.Second,
+		ChangeLog: true,
+	})
+	defer bx.Close()
+
+	todos, _ := bx.New([]byte("todos"))
+	todos.Put([]byte("mon"), []byte("milk cows"))
+	todos.Put([]byte("mon"), []byte("milk goats"))
+	todos.Delete([]byte("mon"))
+
+	entries, _ := bx.ReadLog(0, 10)
+	for _, e := range entries {
+		fmt.Printf("%d %s %s: %q -> %q\n", e.Seq, e.Type, e.Key, e.Old, e.New)
+	}
+
+	// Output:
+	// 1 put mon: "" -> "milk cows"
+	// 2 put mon: "milk cows" -> "milk goats"
+	// 3 delete mon: "milk goats" -> ""
+}
@@ -2,8 +2,10 @@ package buckets_test
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/joyrexus/buckets"
@@ -324,3 +326,61 @@ func ExampleBucket_MapRange() {
 	// 1995 -> 95
 	// 2000 -> 00
 }
+
+// Ensure that Map and friends stop on errors returned by the mapped
+// function, and return them unless they're ErrStopIteration.
+func TestMapErrors(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	letters, err := bx.New([]byte("letters"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("A"), []byte("alpha")},
+		{[]byte("B"), []byte("beta")},
+		{[]byte("C"), []byte("gamma")},
+	}
+	if err := letters.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	maps := map[string]func(do func(k, v []byte) error) error{
+		"Map": letters.Map,
+		"MapPrefix": func(do func(k, v []byte) error) error {
+			return letters.MapPrefix(do, []byte(""))
+		},
+		"MapRange": func(do func(k, v []byte) error) error {
+			return letters.MapRange(do, []byte("A"), []byte("Z"))
+		},
+		"PrefixScanner.Map": letters.NewPrefixScanner([]byte("")).Map,
+		"RangeScanner.Map":  letters.NewRangeScanner([]byte("A"), []byte("Z")).Map,
+	}
+
+	oops := errors.New("oops")
+	for name, m := range maps {
+		for _, stop := range []error{buckets.ErrStopIteration, oops} {
+			var seen []string
+			err := m(func(k, v []byte) error {
+				seen = append(seen, string(v))
+				if bytes.Equal(k, []byte("B")) {
+					return stop
+				}
+				return nil
+			})
+			if want := []string{"alpha", "beta"}; !reflect.DeepEqual(seen, want) {
+				t.Errorf("%s: got %v, want %v", name, seen, want)
+			}
+			if stop == oops && err != oops {
+				t.Errorf("%s: got error %v, want %v", name, err, oops)
+			}
+			if stop != oops && err != nil {
+				t.Errorf("%s: got error %v, want nil", name, err)
+			}
+		}
+	}
+}
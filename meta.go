@@ -0,0 +1,199 @@
+package buckets
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// reserved prefixes the names of the top-level buckets used internally
+// for bookkeeping.  These buckets are hidden from DB.Buckets.
+var reserved = []byte("\x00buckets/")
+
+// metaBucket maps bucket paths (see pathKey) to creation times.
+var metaBucket = []byte("\x00buckets/meta")
+
+// isReserved checks whether `name` is the name of an internal bucket.
+func isReserved(name []byte) bool {
+	return bytes.HasPrefix(name, reserved)
+}
+
+// checkPath returns ErrReservedName if the top-level bucket of `path`
+// is an internal one, which callers mustn't open, create or delete.
+func checkPath(path [][]byte) error {
+	if len(path) > 0 && isReserved(path[0]) {
+		return fmt.Errorf("bucket %q: %w", path[0], ErrReservedName)
+	}
+	return nil
+}
+
+// BucketInfo describes a bucket.  It embeds the bolt.BucketStats for
+// the bucket (key count, depth, page usage, &c.), which include the
+// stats of any nested buckets.
+type BucketInfo struct {
+	Bucket  *Bucket
+	Created time.Time // zero if the creation time was not recorded
+	bolt.BucketStats
+}
+
+// Bucket opens an existing named bucket.  Unlike New, it returns
+// ErrBucketNotFound if the bucket does not exist.
+func (db *DB) Bucket(name []byte) (*Bucket, error) {
+	if err := checkPath([][]byte{name}); err != nil {
+		return nil, err
+	}
+	bk := &Bucket{db: db, Name: name}
+	return bk, db.View(func(tx *bolt.Tx) error {
+		return bk.with(tx, func(b *bolt.Bucket) error { return nil })
+	})
+}
+
+// BucketPath opens an existing nested bucket, the last in `path` (as in
+// NewPath).  Unlike NewPath, it returns ErrBucketNotFound if any bucket
+// along the path does not exist.
+func (db *DB) BucketPath(path ...[]byte) (*Bucket, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("empty bucket path")
+	}
+	if err := checkPath(path); err != nil {
+		return nil, err
+	}
+	bk := db.bucketAt(path)
+	return bk, db.View(func(tx *bolt.Tx) error {
+		return bk.with(tx, func(b *bolt.Bucket) error { return nil })
+	})
+}
+
+// Buckets returns info about each top-level bucket in the database.
+func (db *DB) Buckets() (infos []BucketInfo, err error) {
+	err = db.View(func(tx *bolt.Tx) error {
+		infos, err = db.buckets(tx)
+		return err
+	})
+	return infos, err
+}
+
+// buckets returns info about each top-level bucket within `tx`.
+func (db *DB) buckets(tx *bolt.Tx) (infos []BucketInfo, err error) {
+	err = tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+		if isReserved(name) {
+			return nil
+		}
+		n := make([]byte, len(name))
+		copy(n, name)
+		bk := &Bucket{db: db, Name: n}
+		infos = append(infos, bk.info(tx, b))
+		return nil
+	})
+	return infos, err
+}
+
+// Info returns info about the bucket.
+func (bk *Bucket) Info() (info BucketInfo, err error) {
+	err = bk.view(func(b *bolt.Bucket) error {
+		info = bk.info(b.Tx(), b)
+		return nil
+	})
+	return info, err
+}
+
+// info returns info about bk, whose bolt bucket is `b`.
+func (bk *Bucket) info(tx *bolt.Tx, b *bolt.Bucket) BucketInfo {
+	info := BucketInfo{Bucket: bk, BucketStats: b.Stats()}
+	if meta := tx.Bucket(metaBucket); meta != nil {
+		if v := meta.Get(pathKey(bk.Path())); v != nil {
+			info.Created = decodeTime(v)
+		}
+	}
+	return info
+}
+
+// bucketCreator is implemented by both bolt.Tx and bolt.Bucket.
+type bucketCreator interface {
+	Bucket(name []byte) *bolt.Bucket
+	CreateBucket(name []byte) (*bolt.Bucket, error)
+	DeleteBucket(name []byte) error
+}
+
+// createBucket creates/opens the last bucket in `path` within `parent`,
+// recording when new buckets are created.
+func (db *DB) createBucket(tx *bolt.Tx, parent bucketCreator,
+	path [][]byte) (*bolt.Bucket, error) {
+
+	if err := checkPath(path); err != nil {
+		return nil, err
+	}
+	name := path[len(path)-1]
+	if b := parent.Bucket(name); b != nil {
+		return b, nil
+	}
+	b, err := parent.CreateBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := tx.CreateBucketIfNotExists(metaBucket)
+	if err != nil {
+		return nil, err
+	}
+	return b, meta.Put(pathKey(path), encodeTime(db.now()))
+}
+
+// deleteBucket deletes the last bucket in `path` from `parent`, along
+// with any bookkeeping for it and the buckets nested within it.
+func (db *DB) deleteBucket(tx *bolt.Tx, parent bucketCreator,
+	path [][]byte) error {
+
+	if err := checkPath(path); err != nil {
+		return err
+	}
+	if err := parent.DeleteBucket(path[len(path)-1]); err != nil {
+		return err
+	}
+	pre := pathKey(path)
+	for _, name := range [][]byte{metaBucket, ttlBucket, indexBucket} {
+		if err := deletePrefix(tx.Bucket(name), pre); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deletePrefix deletes all keys (and nested buckets) with prefix `pre`
+// from `b`, if any.
+func deletePrefix(b *bolt.Bucket, pre []byte) error {
+	if b == nil {
+		return nil
+	}
+	c := b.Cursor()
+	for k, v := c.Seek(pre); bytes.HasPrefix(k, pre); k, v = c.Seek(pre) {
+		var err error
+		if v == nil {
+			err = b.DeleteBucket(k)
+		} else {
+			err = c.Delete()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeTime encodes `t` as an 8-byte big-endian count of nanoseconds
+// since the unix epoch.
+func encodeTime(t time.Time) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(t.UnixNano()))
+	return buf
+}
+
+// decodeTime decodes a time encoded with encodeTime.
+func decodeTime(buf []byte) time.Time {
+	if len(buf) != 8 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(buf)))
+}
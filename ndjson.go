@@ -0,0 +1,293 @@
+package buckets
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/boltdb/bolt"
+)
+
+// An Encoding specifies how Export writes keys and values (and bucket
+// names) as JSON strings, and how Import reads them.
+type Encoding int
+
+const (
+	// Base64 encodes bytes as base64 strings, so any keys and values
+	// can be exported.
+	Base64 Encoding = iota
+
+	// UTF8 writes bytes as is.  This is more readable, but only works
+	// for keys and values that are valid UTF-8 (e.g., text or JSON).
+	UTF8
+)
+
+// encode returns `b` encoded as a string.
+func (enc Encoding) encode(b []byte) (string, error) {
+	if enc == Base64 {
+		return base64.StdEncoding.EncodeToString(b), nil
+	}
+	if !utf8.Valid(b) {
+		return "", fmt.Errorf("%q is not valid UTF-8", b)
+	}
+	return string(b), nil
+}
+
+// decode returns the bytes encoded in `s`.
+func (enc Encoding) decode(s string) ([]byte, error) {
+	if enc == Base64 {
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return []byte(s), nil
+}
+
+// A record is a line of exported JSON: an item and, for database
+// exports, the path of its bucket.
+type record struct {
+	Bucket []string `json:"bucket,omitempty"`
+	Key    string   `json:"key"`
+	Value  string   `json:"value"`
+}
+
+// Export writes the bucket's items to `w` as newline-delimited JSON,
+// one object per item, with keys and values encoded as per `enc`:
+//
+//	{"key":"Zm9v","value":"YmFy"}
+//
+// Nested buckets are not included.  See DB.Export.
+func (bk *Bucket) Export(w io.Writer, enc Encoding) error {
+	bw := bufio.NewWriter(w)
+	e := json.NewEncoder(bw)
+	err := bk.Map(func(k, v []byte) error {
+		if v == nil {
+			return nil
+		}
+		return writeRecord(e, enc, nil, k, v)
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Export writes the items of every bucket in the database (including
+// nested buckets) to `w` as newline-delimited JSON, as in Bucket.Export.
+// Each object also has the path of the item's bucket:
+//
+//	{"bucket":["dXNlcnM=","NDI="],"key":"Zm9v","value":"YmFy"}
+//
+// The export is a consistent snapshot of the database.
+func (db *DB) Export(w io.Writer, enc Encoding) error {
+	bw := bufio.NewWriter(w)
+	e := json.NewEncoder(bw)
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if isReserved(name) {
+				return nil
+			}
+			return db.export(tx, e, enc, [][]byte{name})
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// export writes the items of the bucket at `path`, and then those of
+// its nested buckets.
+func (db *DB) export(tx *bolt.Tx, e *json.Encoder, enc Encoding,
+	path [][]byte) error {
+
+	bk := db.bucketAt(path)
+	bk.tx = tx
+	var nested [][]byte
+	err := bk.Map(func(k, v []byte) error {
+		if v == nil {
+			nested = append(nested, k)
+			return nil
+		}
+		return writeRecord(e, enc, path, k, v)
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range nested {
+		child := append(append([][]byte{}, path...), name)
+		if err := db.export(tx, e, enc, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRecord writes the record for key `k` and value `v` of the bucket
+// at `path`.
+func writeRecord(e *json.Encoder, enc Encoding, path [][]byte,
+	k, v []byte) (err error) {
+
+	var rec record
+	for _, name := range path {
+		s, err := enc.encode(name)
+		if err != nil {
+			return fmt.Errorf("bucket name: %w", err)
+		}
+		rec.Bucket = append(rec.Bucket, s)
+	}
+	if rec.Key, err = enc.encode(k); err != nil {
+		return fmt.Errorf("key: %w", err)
+	}
+	if rec.Value, err = enc.encode(v); err != nil {
+		return fmt.Errorf("value for key %q: %w", k, err)
+	}
+	return e.Encode(rec)
+}
+
+// ImportOptions configure Import.
+type ImportOptions struct {
+	// Encoding is the encoding of the keys and values.
+	Encoding Encoding
+
+	// BatchSize is the maximum number of items put per transaction.
+	// Defaults to 1000.
+	BatchSize int
+
+	// NoOverwrite skips items whose keys already exist, as with
+	// InsertNX, rather than updating them.
+	NoOverwrite bool
+}
+
+// Import reads items written by Bucket.Export from `r` and puts them in
+// the bucket, returning the number of items read.  The items are put in
+// batches, each sorted by key and inserted in a single transaction.  If
+// an error occurs, the batches before it have been imported.
+func (bk *Bucket) Import(r io.Reader, opts ImportOptions) (int, error) {
+	return importRecords(r, opts, func(path [][]byte) (*Bucket, error) {
+		if path != nil {
+			return nil, errors.New("unexpected bucket in bucket import")
+		}
+		return bk, nil
+	})
+}
+
+// Import reads items written by DB.Export from `r` and puts them in
+// their buckets, which are created as needed, returning the number of
+// items read.  See Bucket.Import.
+func (db *DB) Import(r io.Reader, opts ImportOptions) (int, error) {
+	return importRecords(r, opts, func(path [][]byte) (*Bucket, error) {
+		if path == nil {
+			return nil, errors.New("missing bucket in database import")
+		}
+		return db.NewPath(path...)
+	})
+}
+
+// importRecords reads records from `r` and inserts them in batches into
+// the buckets returned by `open` for their paths.
+func importRecords(r io.Reader, opts ImportOptions,
+	open func(path [][]byte) (*Bucket, error)) (int, error) {
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	var (
+		n     int // records read
+		bk    *Bucket
+		path  [][]byte
+		batch []struct{ Key, Value []byte }
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		defer func() { batch = batch[:0] }()
+		if _, err := insertSorted(bk, batch, opts.NoOverwrite); err != nil {
+			return fmt.Errorf("records %d-%d: %w", n-len(batch)+1, n, err)
+		}
+		return nil
+	}
+
+	d := json.NewDecoder(r)
+	for {
+		var rec record
+		if err := d.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			return n, fmt.Errorf("record %d: %w", n+1, err)
+		}
+		recPath, k, v, err := readRecord(rec, opts.Encoding)
+		if err != nil {
+			return n, fmt.Errorf("record %d: %w", n+1, err)
+		}
+		moved := bk == nil || !samePath(path, recPath)
+		if moved || len(batch) == opts.BatchSize {
+			if err := flush(); err != nil {
+				return n, err
+			}
+		}
+		if moved {
+			if bk, err = open(recPath); err != nil {
+				return n, fmt.Errorf("record %d: %w", n+1, err)
+			}
+			path = recPath
+		}
+		batch = append(batch, struct{ Key, Value []byte }{k, v})
+		n++
+	}
+	return n, flush()
+}
+
+// insertSorted sorts `items` by key and inserts them into bucket `bk`
+// in a single transaction, as with Insert, or with InsertNX if
+// `noOverwrite` is set, returning the number of items put.  Items with
+// equal keys are kept in order, so that the last one wins (or the first,
+// if `noOverwrite` is set).
+func insertSorted(bk *Bucket, items []struct{ Key, Value []byte },
+	noOverwrite bool) (n int, err error) {
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return bytes.Compare(items[i].Key, items[j].Key) < 0
+	})
+	err = bk.update(func(b *bolt.Bucket) error {
+		for _, item := range items {
+			if noOverwrite && bk.get(b, item.Key) != nil {
+				continue
+			}
+			if err := bk.put(b, item.Key, item.Value); err != nil {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// readRecord decodes the bucket path, key and value of a record.
+func readRecord(rec record, enc Encoding) (path [][]byte, k, v []byte,
+	err error) {
+
+	for _, s := range rec.Bucket {
+		name, err := enc.decode(s)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("bucket name: %w", err)
+		}
+		path = append(path, name)
+	}
+	if k, err = enc.decode(rec.Key); err != nil {
+		return nil, nil, nil, fmt.Errorf("key: %w", err)
+	}
+	if v, err = enc.decode(rec.Value); err != nil {
+		return nil, nil, nil, fmt.Errorf("value: %w", err)
+	}
+	return path, k, v, nil
+}
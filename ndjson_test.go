@@ -0,0 +1,149 @@
+package buckets_test
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure that a bucket's items survive an export and import.
+func TestExportImport(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	src, _ := bx.New([]byte("src"))
+	items := []struct{ Key, Value []byte }{
+		{[]byte("a"), []byte("alpha")},
+		{[]byte("b"), []byte{0, 1, 2, 0xff}},
+		{[]byte{0xfe, 0}, []byte("binary key")},
+		{[]byte("empty"), []byte{}},
+	}
+	src.Insert(items)
+	src.New([]byte("nested")) // not exported
+
+	var buf bytes.Buffer
+	if err := src.Export(&buf, buckets.Base64); err != nil {
+		t.Fatal(err.Error())
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != len(items) {
+		t.Errorf("got %d lines, want %d", lines, len(items))
+	}
+
+	dst, _ := bx.New([]byte("dst"))
+	n, err := dst.Import(&buf, buckets.ImportOptions{BatchSize: 3})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if n != len(items) {
+		t.Errorf("got %d items imported, want %d", n, len(items))
+	}
+	want, _ := src.Items()
+	got, _ := dst.Items()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// Binary values can't be exported as UTF-8.
+	if err := src.Export(&buf, buckets.UTF8); err == nil {
+		t.Error("expected error exporting binary value as UTF-8")
+	}
+}
+
+// Ensure that imports can skip existing keys, and report bad records.
+func TestImportOptions(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+	things.Put([]byte("a"), []byte("old"))
+
+	input := `{"key":"b","value":"new"}
+{"key":"a","value":"new"}
+`
+	opts := buckets.ImportOptions{Encoding: buckets.UTF8, NoOverwrite: true}
+	if _, err := things.Import(strings.NewReader(input), opts); err != nil {
+		t.Fatal(err.Error())
+	}
+	for k, want := range map[string]string{"a": "old", "b": "new"} {
+		if got, _ := things.Get([]byte(k)); string(got) != want {
+			t.Errorf("got %s=%s, want %s", k, got, want)
+		}
+	}
+
+	bad := map[string]string{
+		"json":   `{"key":"a","value":"x"}` + "\n" + `{"key":`,
+		"base64": `{"key":"!!!","value":"x"}`,
+		"bucket": `{"bucket":["b"],"key":"YQ==","value":"eA=="}`,
+		"key":    `{"key":"","value":"eA=="}`,
+	}
+	for name, input := range bad {
+		_, err := things.Import(strings.NewReader(input), buckets.ImportOptions{})
+		if err == nil {
+			t.Errorf("%s: expected import error", name)
+		}
+	}
+}
+
+// Ensure that a whole database survives an export and import.
+func TestExportImportDB(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	users, _ := bx.New([]byte("users"))
+	users.Put([]byte("count"), []byte("2"))
+	sessions, _ := bx.NewPath([]byte("users"), []byte("42"), []byte("sessions"))
+	sessions.Put([]byte("s1"), []byte("x"))
+	todos, _ := bx.New([]byte("todos"))
+	todos.Put([]byte("mon"), []byte("milk cows"))
+
+	var buf bytes.Buffer
+	if err := bx.Export(&buf, buckets.UTF8); err != nil {
+		t.Fatal(err.Error())
+	}
+	want := `{"bucket":["todos"],"key":"mon","value":"milk cows"}`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("export %s does not contain %s", buf.String(), want)
+	}
+
+	other := NewTestDB()
+	defer other.Close()
+	n, err := other.Import(&buf, buckets.ImportOptions{Encoding: buckets.UTF8})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if n != 3 {
+		t.Errorf("got %d items imported, want 3", n)
+	}
+	copied, _ := other.NewPath([]byte("users"), []byte("42"), []byte("sessions"))
+	if v, _ := copied.Get([]byte("s1")); string(v) != "x" {
+		t.Errorf("got %s, want x", v)
+	}
+	if got := get(t, other.DB, "todos", "mon"); got != "milk cows" {
+		t.Errorf("got %s, want milk cows", got)
+	}
+}
+
+// Show that we can export a bucket as JSON lines.
+func ExampleBucket_Export() {
+	bx, _ := buckets.Open(tempfile())
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	todos, _ := bx.New([]byte("todos"))
+	todos.Put([]byte("mon"), []byte("milk cows"))
+	todos.Put([]byte("tue"), []byte("fold laundry"))
+
+	todos.Export(os.Stdout, buckets.UTF8)
+	todos.Export(os.Stdout, buckets.Base64)
+
+	// Output:
+	// {"key":"mon","value":"milk cows"}
+	// {"key":"tue","value":"fold laundry"}
+	// {"key":"bW9u","value":"bWlsayBjb3dz"}
+	// {"key":"dHVl","value":"Zm9sZCBsYXVuZHJ5"}
+}
+
@@ -0,0 +1,146 @@
+package buckets_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure we can create, list, and delete nested buckets.
+func TestNestedBuckets(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	users, err := bx.New([]byte("users"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	// Create a couple of buckets nested within `users`.
+	names := [][]byte{[]byte("alice"), []byte("bob")}
+	for _, name := range names {
+		if _, err := users.New(name); err != nil {
+			t.Error(err.Error())
+		}
+	}
+
+	// Plain items in the parent are not listed as buckets.
+	if err := users.Put([]byte("count"), []byte("2")); err != nil {
+		t.Error(err.Error())
+	}
+
+	children, err := users.Buckets()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(children) != len(names) {
+		t.Fatalf("got %d nested buckets, want %d", len(children), len(names))
+	}
+	for i, want := range names {
+		if got := children[i].Name; !bytes.Equal(got, want) {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+
+	if err := users.DeleteBucket([]byte("bob")); err != nil {
+		t.Error(err.Error())
+	}
+
+	children, err = users.Buckets()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(children) != 1 {
+		t.Errorf("got %d nested buckets, want 1", len(children))
+	}
+}
+
+// Ensure that items and scanners work on buckets addressed by path.
+func TestNewPath(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	sessions, err := bx.NewPath(
+		[]byte("users"), []byte("42"), []byte("sessions"),
+	)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	wantPath := "users/42/sessions"
+	if got := bytes.Join(sessions.Path(), []byte("/")); string(got) != wantPath {
+		t.Errorf("got path %s, want %s", got, wantPath)
+	}
+
+	items := []struct {
+		Key, Value []byte
+	}{
+		{[]byte("2016-01-01"), []byte("a")},
+		{[]byte("2016-01-02"), []byte("b")},
+		{[]byte("2016-02-01"), []byte("c")},
+	}
+	if err := sessions.Insert(items); err != nil {
+		t.Error(err.Error())
+	}
+
+	// The same bucket can be reached by walking down from the top.
+	users, _ := bx.New([]byte("users"))
+	user, _ := users.New([]byte("42"))
+	again, _ := user.New([]byte("sessions"))
+
+	got, err := again.Get([]byte("2016-01-02"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if want := []byte("b"); !bytes.Equal(got, want) {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	jan := sessions.NewPrefixScanner([]byte("2016-01"))
+	count, err := jan.Count()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if count != 2 {
+		t.Errorf("got %d items with prefix, want 2", count)
+	}
+
+	all, err := again.Items()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(all) != len(items) {
+		t.Errorf("got %d items, want %d", len(all), len(items))
+	}
+}
+
+// Show that we can work with buckets nested within other buckets.
+func ExampleBucket_New() {
+	bx, _ := buckets.Open(tempfile())
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	// Create a `users` bucket with a nested bucket for each user.
+	users, _ := bx.New([]byte("users"))
+	alice, _ := users.New([]byte("alice"))
+	users.New([]byte("bob"))
+
+	// Put key/value into the nested `alice` bucket.
+	alice.Put([]byte("email"), []byte("alice@example.com"))
+
+	children, _ := users.Buckets()
+	for _, child := range children {
+		fmt.Printf("%s\n", bytes.Join(child.Path(), []byte("/")))
+	}
+
+	email, _ := alice.Get([]byte("email"))
+	fmt.Printf("alice's email is %s\n", email)
+
+	// Output:
+	// users/alice
+	// users/bob
+	// alice's email is alice@example.com
+}
@@ -0,0 +1,133 @@
+package buckets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// Options represents the options that can be set when opening a database.
+type Options struct {
+	// ReadOnly opens the database in read-only mode.  A read-only
+	// database takes a shared lock on the file, so several processes
+	// can open it read-only at once, but not while another process
+	// has it open for writing.
+	ReadOnly bool
+
+	// Timeout is the amount of time to wait to obtain a file lock.
+	// When set to zero it will wait indefinitely.
+	Timeout time.Duration
+
+	// FileMode is the mode used when creating the database file.
+	// Defaults to 0600.
+	FileMode os.FileMode
+
+	// NoSync skips fsync() calls after each commit.  This can speed up
+	// bulk loads, but a crash may corrupt the database.
+	NoSync bool
+
+	// NoGrowSync skips fsync() calls when growing the database file.
+	NoGrowSync bool
+
+	// InitialMmapSize is the initial size of the memory map, in bytes.
+	// A large enough value avoids remapping (and the blocking of write
+	// transactions by long-running reads) as the database grows.
+	InitialMmapSize int
+
+	// Clock returns the current time, for deciding when items put with
+	// PutWithTTL expire.  Defaults to time.Now.
+	Clock func() time.Time
+
+	// Retry specifies how to retry opening the database when the file
+	// lock cannot be obtained within Timeout.
+	Retry RetryPolicy
+
+	// ChangeLog enables the database's change log (see DB.ReadLog),
+	// saving LogRetention as its retention policy.  The setting is saved
+	// in the database file: once enabled, every put and delete is logged,
+	// whether or not the database is opened with ChangeLog.  It's
+	// ignored when opening read-only.
+	ChangeLog bool
+
+	// LogRetention limits the number of entries kept in the change log.
+	// It replaces any policy saved earlier if ChangeLog is set.
+	LogRetention LogRetention
+}
+
+// A RetryPolicy describes how often to retry an operation that failed.
+type RetryPolicy struct {
+	// Attempts is the number of retries after the first attempt.
+	Attempts int
+
+	// Delay is the amount of time to wait before the first retry.
+	// It doubles after each retry.
+	Delay time.Duration
+
+	// OnRetry, if set, is called before each retry (numbered from 1)
+	// with the error of the attempt before it: e.g., to log it.
+	OnRetry func(retry int, err error)
+}
+
+// DefaultOptions are the options used by Open.
+var DefaultOptions = &Options{
+	Timeout:  1 * time.Second,
+	FileMode: 0600,
+}
+
+// OpenWithOptions creates/opens a buckets database at the specified path,
+// using the given options.  If `options` is nil, DefaultOptions are used.
+func OpenWithOptions(path string, options *Options) (*DB, error) {
+	if options == nil {
+		options = DefaultOptions
+	}
+	opts := *options
+	db, err := openBolt(path, &opts)
+	if err != nil {
+		return nil, err
+	}
+	bx := &DB{DB: db, clock: options.Clock, options: &opts}
+	if options.ChangeLog && !options.ReadOnly {
+		err := db.Update(func(tx *bolt.Tx) error {
+			return enableChangeLog(tx, options.LogRetention)
+		})
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("couldn't enable change log: %w", err)
+		}
+	}
+	return bx, nil
+}
+
+// openBolt opens the bolt database at `path` with `options`, retrying
+// as per options.Retry.
+func openBolt(path string, options *Options) (*bolt.DB, error) {
+	mode := options.FileMode
+	if mode == 0 {
+		mode = 0600
+	}
+	config := &bolt.Options{
+		Timeout:         options.Timeout,
+		NoGrowSync:      options.NoGrowSync,
+		ReadOnly:        options.ReadOnly,
+		InitialMmapSize: options.InitialMmapSize,
+	}
+
+	db, err := bolt.Open(path, mode, config)
+	delay := options.Retry.Delay
+	for i := 0; i < options.Retry.Attempts && errors.Is(err, ErrTimeout); i++ {
+		if options.Retry.OnRetry != nil {
+			options.Retry.OnRetry(i+1, err)
+		}
+		time.Sleep(delay)
+		delay *= 2
+		db, err = bolt.Open(path, mode, config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open %s: %w", path, err)
+	}
+	db.NoSync = options.NoSync
+	return db, nil
+}
@@ -0,0 +1,90 @@
+package buckets
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+// Page returns up to `limit` items from the bucket, in key order,
+// starting after the position marked by the continuation token `after`.
+// Pass a nil token to get the first page.
+//
+// It also returns a continuation token for getting the next page, which
+// is nil if there are no more items.  Since each page picks up after the
+// last key returned, paging is unaffected by items inserted or deleted
+// between pages (other than the changes showing up or not).  Treat the
+// token as opaque.
+func (bk *Bucket) Page(limit int, after []byte) (items []Item, next []byte,
+	err error) {
+
+	err = bk.view(func(b *bolt.Bucket) error {
+		in := func(k []byte) bool { return k != nil }
+		items, next, err = page(bk.cursor(b), nil, after, in, limit)
+		return err
+	})
+	return items, next, err
+}
+
+// Page returns up to `limit` items for keys with prefix, starting after
+// the position marked by the continuation token `after`.  See Bucket.Page.
+func (ps *PrefixScanner) Page(limit int, after []byte) (items []Item,
+	next []byte, err error) {
+
+	pre := ps.Prefix
+	err = ps.bk.view(func(b *bolt.Bucket) error {
+		in := func(k []byte) bool { return bytes.HasPrefix(k, pre) }
+		items, next, err = page(ps.bk.cursor(b), pre, after, in, limit)
+		return err
+	})
+	return items, next, err
+}
+
+// Page returns up to `limit` items for keys within the range, starting
+// after the position marked by the continuation token `after`.
+// See Bucket.Page.
+func (rs *RangeScanner) Page(limit int, after []byte) (items []Item,
+	next []byte, err error) {
+
+	// Pick up after Min itself if it's excluded from the range.
+	if rs.ExcludeMin && rs.Min != nil &&
+		(after == nil || bytes.Compare(after, rs.Min) < 0) {
+		after = rs.Min
+	}
+	err = rs.bk.view(func(b *bolt.Bucket) error {
+		items, next, err = page(rs.bk.cursor(b), rs.Min, after, rs.within, limit)
+		return err
+	})
+	return items, next, err
+}
+
+// page collects up to `limit` items from cursor `c`, starting at key
+// `start` or after key `after`, whichever comes later, and continuing
+// while `in` holds for the keys.  Nested buckets are skipped.
+func page(c *cursor, start, after []byte, in func(k []byte) bool,
+	limit int) ([]Item, []byte, error) {
+
+	if limit <= 0 {
+		return nil, nil, fmt.Errorf("invalid page limit: %d", limit)
+	}
+	seek := start
+	if after != nil && bytes.Compare(after, start) >= 0 {
+		seek = after
+	}
+	k, v := c.Seek(seek)
+	if after != nil && bytes.Equal(k, after) {
+		k, v = c.Next()
+	}
+	var items []Item
+	for ; in(k); k, v = c.Next() {
+		if v == nil {
+			continue
+		}
+		if len(items) == limit {
+			return items, clone(items[limit-1].Key), nil
+		}
+		items = append(items, Item{clone(k), clone(v)})
+	}
+	return items, nil, nil
+}
@@ -0,0 +1,139 @@
+package buckets_test
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// pager gets a page of items after a continuation token.
+type pager func(limit int, after []byte) ([]buckets.Item, []byte, error)
+
+// pages collects the keys on each page of `p`.
+func pages(t *testing.T, p pager, limit int) (keys [][]string) {
+	var after []byte
+	for {
+		items, next, err := p(limit, after)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		var page []string
+		for _, item := range items {
+			page = append(page, string(item.Key))
+		}
+		keys = append(keys, page)
+		if next == nil {
+			return keys
+		}
+		after = next
+	}
+}
+
+// Ensure we can page through buckets and scans.
+func TestPage(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+	for _, k := range []string{"a1", "a2", "a3", "a4", "a5", "b1", "b2"} {
+		things.Put([]byte(k), []byte(k))
+	}
+
+	tests := []struct {
+		name  string
+		pager pager
+		want  [][]string
+	}{
+		{
+			"bucket", things.Page,
+			[][]string{{"a1", "a2", "a3"}, {"a4", "a5", "b1"}, {"b2"}},
+		},
+		{
+			"prefix", things.NewPrefixScanner([]byte("a")).Page,
+			[][]string{{"a1", "a2", "a3"}, {"a4", "a5"}},
+		},
+		{
+			"range", things.NewRangeScanner([]byte("a2"), []byte("b1")).Page,
+			[][]string{{"a2", "a3", "a4"}, {"a5", "b1"}},
+		},
+	}
+	for _, test := range tests {
+		if got := pages(t, test.pager, 3); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// Ensure that paging is stable when items change between pages.
+func TestPageChanges(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+	for _, k := range []string{"a", "c", "e", "g"} {
+		things.Put([]byte(k), []byte(k))
+	}
+
+	items, next, err := things.Page(2, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(items) != 2 || string(next) == "" {
+		t.Fatalf("got %d items and token %q", len(items), next)
+	}
+
+	// Delete the last key returned and insert keys before and after it.
+	things.Delete([]byte("c"))
+	things.Put([]byte("b"), []byte("b"))
+	things.Put([]byte("d"), []byte("d"))
+
+	items, next, err = things.Page(5, next)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var got []string
+	for _, item := range items {
+		got = append(got, string(item.Key))
+	}
+	if want := []string{"d", "e", "g"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if next != nil {
+		t.Errorf("got token %q, want nil", next)
+	}
+}
+
+// Show that we can get items one page at a time.
+func ExampleBucket_Page() {
+	bx, _ := buckets.Open(tempfile())
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	letters, _ := bx.New([]byte("letters"))
+	for _, k := range []string{"A", "B", "C", "D", "E"} {
+		letters.Put([]byte(k), []byte(k))
+	}
+
+	var token []byte
+	for {
+		items, next, _ := letters.Page(2, token)
+		var keys []string
+		for _, item := range items {
+			keys = append(keys, string(item.Key))
+		}
+		fmt.Println(strings.Join(keys, " "))
+		if next == nil {
+			break
+		}
+		token = next
+	}
+
+	// Output:
+	// A B
+	// C D
+	// E
+}
@@ -7,29 +7,37 @@ import (
 )
 
 // A PrefixScanner scans a bucket for keys with a given prefix.
+//
+// The keys and values returned by its methods are copies, safe to use
+// after the method returns.  Use Map or ViewItems to work with them
+// in place.
 type PrefixScanner struct {
-	db         *DB
+	bk         *Bucket
 	BucketName []byte
 	Prefix     []byte
 }
 
 // Map applies `do` on each key/value pair for keys with prefix.
+// If `do` returns an error, the scan stops and Map returns the error,
+// unless it's ErrStopIteration.
 func (ps *PrefixScanner) Map(do func(k, v []byte) error) error {
 	pre := ps.Prefix
-	return ps.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(ps.BucketName).Cursor()
-		for k, v := c.Seek(pre); bytes.HasPrefix(k, pre); k, _ = c.Next() {
-			do(k, v)
+	return stopped(ps.bk.view(func(b *bolt.Bucket) error {
+		c := ps.bk.cursor(b)
+		for k, v := c.Seek(pre); bytes.HasPrefix(k, pre); k, v = c.Next() {
+			if err := do(k, v); err != nil {
+				return err
+			}
 		}
 		return nil
-	})
+	}))
 }
 
 // Count returns a count of the keys with prefix.
 func (ps *PrefixScanner) Count() (count int, err error) {
 	pre := ps.Prefix
-	err = ps.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(ps.BucketName).Cursor()
+	err = ps.bk.view(func(b *bolt.Bucket) error {
+		c := ps.bk.cursor(b)
 		for k, _ := c.Seek(pre); bytes.HasPrefix(k, pre); k, _ = c.Next() {
 			count++
 		}
@@ -44,10 +52,10 @@ func (ps *PrefixScanner) Count() (count int, err error) {
 // Keys returns a slice of keys with prefix.
 func (ps *PrefixScanner) Keys() (keys [][]byte, err error) {
 	pre := ps.Prefix
-	err = ps.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(ps.BucketName).Cursor()
+	err = ps.bk.view(func(b *bolt.Bucket) error {
+		c := ps.bk.cursor(b)
 		for k, _ := c.Seek(pre); bytes.HasPrefix(k, pre); k, _ = c.Next() {
-			keys = append(keys, k)
+			keys = append(keys, clone(k))
 		}
 		return nil
 	})
@@ -60,10 +68,10 @@ func (ps *PrefixScanner) Keys() (keys [][]byte, err error) {
 // Values returns a slice of values for keys with prefix.
 func (ps *PrefixScanner) Values() (values [][]byte, err error) {
 	pre := ps.Prefix
-	err = ps.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(ps.BucketName).Cursor()
+	err = ps.bk.view(func(b *bolt.Bucket) error {
+		c := ps.bk.cursor(b)
 		for k, v := c.Seek(pre); bytes.HasPrefix(k, pre); k, v = c.Next() {
-			values = append(values, v)
+			values = append(values, clone(v))
 		}
 		return nil
 	})
@@ -76,10 +84,10 @@ func (ps *PrefixScanner) Values() (values [][]byte, err error) {
 // Items returns a slice of key/value pairs for keys with prefix.
 func (ps *PrefixScanner) Items() (items []Item, err error) {
 	pre := ps.Prefix
-	err = ps.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(ps.BucketName).Cursor()
+	err = ps.bk.view(func(b *bolt.Bucket) error {
+		c := ps.bk.cursor(b)
 		for k, v := c.Seek(pre); bytes.HasPrefix(k, pre); k, v = c.Next() {
-			items = append(items, Item{k, v})
+			items = append(items, Item{clone(k), clone(v)})
 		}
 		return nil
 	})
@@ -94,10 +102,10 @@ func (ps *PrefixScanner) Items() (items []Item, err error) {
 func (ps *PrefixScanner) ItemMapping() (map[string][]byte, error) {
 	pre := ps.Prefix
 	items := make(map[string][]byte)
-	err := ps.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(ps.BucketName).Cursor()
+	err := ps.bk.view(func(b *bolt.Bucket) error {
+		c := ps.bk.cursor(b)
 		for k, v := c.Seek(pre); bytes.HasPrefix(k, pre); k, v = c.Next() {
-			items[string(k)] = v
+			items[string(k)] = clone(v)
 		}
 		return nil
 	})
@@ -106,3 +114,19 @@ func (ps *PrefixScanner) ItemMapping() (map[string][]byte, error) {
 	}
 	return items, err
 }
+
+// ViewItems applies `fn` to the key/value pairs for keys with prefix,
+// without copying them out of the database.  This avoids allocations,
+// but the items are only valid until `fn` returns (the transaction is
+// held open until then): they must not be modified or retained.
+func (ps *PrefixScanner) ViewItems(fn func(items []Item) error) error {
+	pre := ps.Prefix
+	return ps.bk.view(func(b *bolt.Bucket) error {
+		var items []Item
+		c := ps.bk.cursor(b)
+		for k, v := c.Seek(pre); bytes.HasPrefix(k, pre); k, v = c.Next() {
+			items = append(items, Item{k, v})
+		}
+		return fn(items)
+	})
+}
@@ -2,7 +2,10 @@ package buckets_test
 
 import (
 	"bytes"
+	"fmt"
 	"testing"
+
+	"github.com/joyrexus/buckets"
 )
 
 // Ensure we can scan prefixes.
@@ -117,3 +120,64 @@ func TestPrefixScanner(t *testing.T) {
 		t.Error(err.Error())
 	}
 }
+
+// Ensure that scanned items are copies that outlive their transaction.
+func TestPrefixScannerCopies(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+	things.Put([]byte("A1"), []byte("alpha"))
+	things.Put([]byte("A2"), []byte("beta"))
+
+	scanner := things.NewPrefixScanner([]byte("A"))
+	items, err := scanner.Items()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	keys, _ := scanner.Keys()
+	values, _ := scanner.Values()
+
+	// Grow the database enough to remap the data file.
+	for i := 0; i < 1000; i++ {
+		k := []byte(fmt.Sprintf("B%04d", i))
+		things.Put(k, bytes.Repeat([]byte("x"), 1024))
+	}
+
+	// Modifying the results must not touch the database.
+	for _, item := range items {
+		item.Value[0] = 'X'
+	}
+	if !bytes.Equal(keys[0], []byte("A1")) || !bytes.Equal(values[1], []byte("beta")) {
+		t.Errorf("got keys %q and values %q", keys, values)
+	}
+	if v, _ := things.Get([]byte("A1")); !bytes.Equal(v, []byte("alpha")) {
+		t.Errorf("got %s, want alpha", v)
+	}
+}
+
+// Ensure we can view scanned items without copying them.
+func TestPrefixScannerViewItems(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+	things.Put([]byte("A1"), []byte("alpha"))
+	things.Put([]byte("A2"), []byte("beta"))
+	things.Put([]byte("B1"), []byte("gamma"))
+
+	total := 0
+	err := things.NewPrefixScanner([]byte("A")).ViewItems(
+		func(items []buckets.Item) error {
+			for _, item := range items {
+				total += len(item.Value)
+			}
+			return nil
+		})
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if total != len("alpha")+len("beta") {
+		t.Errorf("got total length %d, want 9", total)
+	}
+}
@@ -1,31 +1,50 @@
 package buckets
 
-import "github.com/boltdb/bolt"
+import (
+	"bytes"
+
+	"github.com/boltdb/bolt"
+)
 
 // A RangeScanner scans a bucket for keys within a given range.
+//
+// Both bounds are inclusive by default.  Set ExcludeMin or ExcludeMax
+// to leave out keys equal to Min or Max; e.g., set ExcludeMax for a
+// half-open range [Min, Max).  A nil Min scans from the first key and
+// a nil Max scans to the last.
+//
+// The keys and values returned by its methods are copies, safe to use
+// after the method returns.  Use Map or ViewItems to work with them
+// in place.
 type RangeScanner struct {
-	db         *DB
+	bk         *Bucket
 	BucketName []byte
 	Min        []byte
 	Max        []byte
+	ExcludeMin bool
+	ExcludeMax bool
 }
 
 // Map applies `do` on each key/value pair for keys within range.
+// If `do` returns an error, the scan stops and Map returns the error,
+// unless it's ErrStopIteration.
 func (rs *RangeScanner) Map(do func(k, v []byte) error) error {
-	return rs.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(rs.BucketName).Cursor()
-		for k, v := c.Seek(rs.Min); isBefore(k, rs.Max); k, v = c.Next() {
-			do(k, v)
+	return stopped(rs.bk.view(func(b *bolt.Bucket) error {
+		c := rs.bk.cursor(b)
+		for k, v := rs.first(c); rs.within(k); k, v = c.Next() {
+			if err := do(k, v); err != nil {
+				return err
+			}
 		}
 		return nil
-	})
+	}))
 }
 
 // Count returns a count of the keys within the range.
 func (rs *RangeScanner) Count() (count int, err error) {
-	err = rs.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(rs.BucketName).Cursor()
-		for k, _ := c.Seek(rs.Min); isBefore(k, rs.Max); k, _ = c.Next() {
+	err = rs.bk.view(func(b *bolt.Bucket) error {
+		c := rs.bk.cursor(b)
+		for k, _ := rs.first(c); rs.within(k); k, _ = c.Next() {
 			count++
 		}
 		return nil
@@ -38,10 +57,10 @@ func (rs *RangeScanner) Count() (count int, err error) {
 
 // Keys returns a slice of keys within the range.
 func (rs *RangeScanner) Keys() (keys [][]byte, err error) {
-	err = rs.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(rs.BucketName).Cursor()
-		for k, _ := c.Seek(rs.Min); isBefore(k, rs.Max); k, _ = c.Next() {
-			keys = append(keys, k)
+	err = rs.bk.view(func(b *bolt.Bucket) error {
+		c := rs.bk.cursor(b)
+		for k, _ := rs.first(c); rs.within(k); k, _ = c.Next() {
+			keys = append(keys, clone(k))
 		}
 		return nil
 	})
@@ -53,10 +72,10 @@ func (rs *RangeScanner) Keys() (keys [][]byte, err error) {
 
 // Values returns a slice of values for keys within the range.
 func (rs *RangeScanner) Values() (values [][]byte, err error) {
-	err = rs.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(rs.BucketName).Cursor()
-		for k, v := c.Seek(rs.Min); isBefore(k, rs.Max); k, v = c.Next() {
-			values = append(values, v)
+	err = rs.bk.view(func(b *bolt.Bucket) error {
+		c := rs.bk.cursor(b)
+		for k, v := rs.first(c); rs.within(k); k, v = c.Next() {
+			values = append(values, clone(v))
 		}
 		return nil
 	})
@@ -69,10 +88,10 @@ func (rs *RangeScanner) Values() (values [][]byte, err error) {
 // Items returns a slice of key/value pairs for keys within the range.
 // Note that the returned slice contains elements of type Item.
 func (rs *RangeScanner) Items() (items []Item, err error) {
-	err = rs.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(rs.BucketName).Cursor()
-		for k, v := c.Seek(rs.Min); isBefore(k, rs.Max); k, v = c.Next() {
-			items = append(items, Item{k, v})
+	err = rs.bk.view(func(b *bolt.Bucket) error {
+		c := rs.bk.cursor(b)
+		for k, v := rs.first(c); rs.within(k); k, v = c.Next() {
+			items = append(items, Item{clone(k), clone(v)})
 		}
 		return nil
 	})
@@ -86,10 +105,10 @@ func (rs *RangeScanner) Items() (items []Item, err error) {
 // This only works with buckets whose keys are byte-sliced strings.
 func (rs *RangeScanner) ItemMapping() (map[string][]byte, error) {
 	items := make(map[string][]byte)
-	err := rs.db.View(func(tx *bolt.Tx) error {
-		c := tx.Bucket(rs.BucketName).Cursor()
-		for k, v := c.Seek(rs.Min); isBefore(k, rs.Max); k, v = c.Next() {
-			items[string(k)] = v
+	err := rs.bk.view(func(b *bolt.Bucket) error {
+		c := rs.bk.cursor(b)
+		for k, v := rs.first(c); rs.within(k); k, v = c.Next() {
+			items[string(k)] = clone(v)
 		}
 		return nil
 	})
@@ -98,3 +117,47 @@ func (rs *RangeScanner) ItemMapping() (map[string][]byte, error) {
 	}
 	return items, err
 }
+
+// ViewItems applies `fn` to the key/value pairs for keys within the range,
+// without copying them out of the database.  This avoids allocations,
+// but the items are only valid until `fn` returns (the transaction is
+// held open until then): they must not be modified or retained.
+func (rs *RangeScanner) ViewItems(fn func(items []Item) error) error {
+	return rs.bk.view(func(b *bolt.Bucket) error {
+		var items []Item
+		c := rs.bk.cursor(b)
+		for k, v := rs.first(c); rs.within(k); k, v = c.Next() {
+			items = append(items, Item{k, v})
+		}
+		return fn(items)
+	})
+}
+
+// first moves `c` to the first key within the range and returns its
+// key and value.
+func (rs *RangeScanner) first(c *cursor) ([]byte, []byte) {
+	k, v := c.Seek(rs.Min)
+	if rs.ExcludeMin && rs.Min != nil && bytes.Equal(k, rs.Min) {
+		return c.Next()
+	}
+	return k, v
+}
+
+// within checks whether `key`, reached by scanning forward from the
+// start of the range, is still within it.
+func (rs *RangeScanner) within(key []byte) bool {
+	if key == nil || rs.Max == nil {
+		return key != nil
+	}
+	cmp := bytes.Compare(key, rs.Max)
+	return cmp < 0 || cmp == 0 && !rs.ExcludeMax
+}
+
+// above checks whether `key` is at or after the start of the range.
+func (rs *RangeScanner) above(key []byte) bool {
+	if rs.Min == nil {
+		return true
+	}
+	cmp := bytes.Compare(key, rs.Min)
+	return cmp > 0 || cmp == 0 && !rs.ExcludeMin
+}
@@ -2,7 +2,12 @@ package buckets_test
 
 import (
 	"bytes"
+	"fmt"
+	"os"
+	"reflect"
 	"testing"
+
+	"github.com/joyrexus/buckets"
 )
 
 // Ensures we can scan ranges.
@@ -127,3 +132,139 @@ func TestRangeScanner(t *testing.T) {
 		t.Error(err.Error())
 	}
 }
+
+// Ensure that range-scanned items are copies and can also be viewed
+// in place.
+func TestRangeScannerCopies(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	years, _ := bx.New([]byte("years"))
+	years.Put([]byte("1990"), []byte("90"))
+	years.Put([]byte("1995"), []byte("95"))
+
+	nineties := years.NewRangeScanner([]byte("1990"), []byte("1999"))
+	mapping, err := nineties.ItemMapping()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	mapping["1990"][0] = 'X'
+
+	err = nineties.ViewItems(func(items []buckets.Item) error {
+		if len(items) != 2 {
+			t.Errorf("got %d items, want 2", len(items))
+		}
+		if got := items[0].Value; !bytes.Equal(got, []byte("90")) {
+			t.Errorf("got %s, want 90", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+}
+
+// Ensure that range bounds can be exclusive or left open.
+func TestRangeScannerBounds(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	years, _ := bx.New([]byte("years"))
+	for _, k := range []string{"1985", "1990", "1995", "2000", "2005"} {
+		years.Put([]byte(k), []byte(k[2:]))
+	}
+
+	tests := []struct {
+		min, max               []byte
+		excludeMin, excludeMax bool
+		want                   []string
+	}{
+		{[]byte("1990"), []byte("2000"), false, false,
+			[]string{"1990", "1995", "2000"}},
+		{[]byte("1990"), []byte("2000"), false, true,
+			[]string{"1990", "1995"}},
+		{[]byte("1990"), []byte("2000"), true, false,
+			[]string{"1995", "2000"}},
+		{[]byte("1990"), []byte("2000"), true, true,
+			[]string{"1995"}},
+		{nil, []byte("1995"), false, true,
+			[]string{"1985", "1990"}},
+		{[]byte("1995"), nil, true, false,
+			[]string{"2000", "2005"}},
+		{nil, nil, true, true,
+			[]string{"1985", "1990", "1995", "2000", "2005"}},
+		{[]byte("1991"), []byte("1999"), true, true,
+			[]string{"1995"}},
+		{[]byte("2000"), []byte("2000"), false, true,
+			nil},
+	}
+
+	for _, tt := range tests {
+		rs := years.NewRangeScanner(tt.min, tt.max)
+		rs.ExcludeMin, rs.ExcludeMax = tt.excludeMin, tt.excludeMax
+		name := fmt.Sprintf("min=%s max=%s excl=%v/%v",
+			tt.min, tt.max, tt.excludeMin, tt.excludeMax)
+
+		items, err := rs.Items()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if got := keyStrings(items); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", name, got, tt.want)
+		}
+
+		count, err := rs.Count()
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if count != len(tt.want) {
+			t.Errorf("%s: got count %d, want %d", name, count, len(tt.want))
+		}
+
+		reversed, err := rs.ReverseItems()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		got := keyStrings(reversed)
+		for i, j := 0, len(got)-1; i < j; i, j = i+1, j-1 {
+			got[i], got[j] = got[j], got[i]
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got reversed %v, want %v", name, got, tt.want)
+		}
+
+		var paged []string
+		for _, page := range pages(t, rs.Page, 1) {
+			paged = append(paged, page...)
+		}
+		if !reflect.DeepEqual(paged, tt.want) {
+			t.Errorf("%s: got pages %v, want %v", name, paged, tt.want)
+		}
+	}
+}
+
+// Show that we can scan a half-open range of days.
+func ExampleRangeScanner_halfOpen() {
+	bx, _ := buckets.Open(tempfile())
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	events, _ := bx.New([]byte("events"))
+	events.Put([]byte("2016-01-31/a"), []byte("party"))
+	events.Put([]byte("2016-02-01"), []byte("cleanup"))
+	events.Put([]byte("2016-02-01/b"), []byte("brunch"))
+	events.Put([]byte("2016-02-02/c"), []byte("nap"))
+
+	// Scan all of February 1st: [2016-02-01, 2016-02-02).
+	day := events.NewRangeScanner([]byte("2016-02-01"), []byte("2016-02-02"))
+	day.ExcludeMax = true
+
+	values, _ := day.Values()
+	for _, v := range values {
+		fmt.Printf("%s\n", v)
+	}
+
+	// Output:
+	// cleanup
+	// brunch
+}
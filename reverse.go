@@ -0,0 +1,243 @@
+package buckets
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+// ReverseItems returns a slice of key/value pairs in descending
+// key order.  Like the other Reverse methods returning items or keys
+// (but unlike ReverseMap), it skips nested buckets.
+func (bk *Bucket) ReverseItems() (items []Item, err error) {
+	err = bk.ReverseMap(func(k, v []byte) error {
+		if v != nil {
+			items = append(items, Item{clone(k), clone(v)})
+		}
+		return nil
+	})
+	return items, err
+}
+
+// ReverseKeys returns a slice of keys in descending order.
+func (bk *Bucket) ReverseKeys() (keys [][]byte, err error) {
+	err = bk.ReverseMap(func(k, v []byte) error {
+		if v != nil {
+			keys = append(keys, clone(k))
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// ReverseMap applies `do` on each key/value pair in descending key
+// order.  Errors returned by `do` are handled as in Map.
+func (bk *Bucket) ReverseMap(do func(k, v []byte) error) error {
+	return stopped(bk.view(func(b *bolt.Bucket) error {
+		return reverseMap(bk.cursor(b), bk.span(), nil, do)
+	}))
+}
+
+// ReversePage is like Page, but returns items in descending key order,
+// starting before the position marked by the continuation token `after`.
+func (bk *Bucket) ReversePage(limit int, after []byte) (items []Item,
+	next []byte, err error) {
+
+	err = bk.view(func(b *bolt.Bucket) error {
+		items, next, err = reversePage(bk.cursor(b), bk.span(), after, limit)
+		return err
+	})
+	return items, next, err
+}
+
+// span returns the span of all the bucket's keys.
+func (bk *Bucket) span() span {
+	return span{in: func(k []byte) bool { return true }}
+}
+
+// ReverseItems returns a slice of key/value pairs for keys with prefix,
+// in descending key order.
+func (ps *PrefixScanner) ReverseItems() (items []Item, err error) {
+	err = ps.ReverseMap(func(k, v []byte) error {
+		if v != nil {
+			items = append(items, Item{clone(k), clone(v)})
+		}
+		return nil
+	})
+	return items, err
+}
+
+// ReverseKeys returns a slice of keys with prefix, in descending order.
+func (ps *PrefixScanner) ReverseKeys() (keys [][]byte, err error) {
+	err = ps.ReverseMap(func(k, v []byte) error {
+		if v != nil {
+			keys = append(keys, clone(k))
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// ReverseMap applies `do` on each key/value pair for keys with prefix,
+// in descending key order.  Errors returned by `do` are handled as in Map.
+func (ps *PrefixScanner) ReverseMap(do func(k, v []byte) error) error {
+	return stopped(ps.bk.view(func(b *bolt.Bucket) error {
+		return reverseMap(ps.bk.cursor(b), ps.span(), nil, do)
+	}))
+}
+
+// ReversePage is like Page, but returns items in descending key order,
+// starting before the position marked by the continuation token `after`.
+func (ps *PrefixScanner) ReversePage(limit int, after []byte) (items []Item,
+	next []byte, err error) {
+
+	err = ps.bk.view(func(b *bolt.Bucket) error {
+		items, next, err = reversePage(ps.bk.cursor(b), ps.span(), after, limit)
+		return err
+	})
+	return items, next, err
+}
+
+// span returns the span of keys with prefix.  These keys all come before
+// the prefix's successor (e.g., "ab" for prefix "aa").
+func (ps *PrefixScanner) span() span {
+	pre := ps.Prefix
+	return span{
+		max:       successor(pre),
+		exclusive: true,
+		in:        func(k []byte) bool { return bytes.HasPrefix(k, pre) },
+	}
+}
+
+// ReverseItems returns a slice of key/value pairs for keys within the
+// range, in descending key order.
+func (rs *RangeScanner) ReverseItems() (items []Item, err error) {
+	err = rs.ReverseMap(func(k, v []byte) error {
+		if v != nil {
+			items = append(items, Item{clone(k), clone(v)})
+		}
+		return nil
+	})
+	return items, err
+}
+
+// ReverseKeys returns a slice of keys within the range, in descending
+// order.
+func (rs *RangeScanner) ReverseKeys() (keys [][]byte, err error) {
+	err = rs.ReverseMap(func(k, v []byte) error {
+		if v != nil {
+			keys = append(keys, clone(k))
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// ReverseMap applies `do` on each key/value pair for keys within the
+// range, in descending key order.  Errors returned by `do` are handled
+// as in Map.
+func (rs *RangeScanner) ReverseMap(do func(k, v []byte) error) error {
+	return stopped(rs.bk.view(func(b *bolt.Bucket) error {
+		return reverseMap(rs.bk.cursor(b), rs.span(), nil, do)
+	}))
+}
+
+// ReversePage is like Page, but returns items in descending key order,
+// starting before the position marked by the continuation token `after`.
+func (rs *RangeScanner) ReversePage(limit int, after []byte) (items []Item,
+	next []byte, err error) {
+
+	err = rs.bk.view(func(b *bolt.Bucket) error {
+		items, next, err = reversePage(rs.bk.cursor(b), rs.span(), after, limit)
+		return err
+	})
+	return items, next, err
+}
+
+// span returns the span of keys within the range.
+func (rs *RangeScanner) span() span {
+	return span{max: rs.Max, exclusive: rs.ExcludeMax, in: rs.above}
+}
+
+// A span describes the keys of a scan, for scanning them in reverse.
+type span struct {
+	max       []byte // keys are at or before max; nil if unbounded
+	exclusive bool   // keys are strictly before max
+	in        func(k []byte) bool
+}
+
+// last moves `c` to the last key in the span, or to the last key
+// before `after` if set, and returns its key and value.
+func (s span) last(c *cursor, after []byte) ([]byte, []byte) {
+	if after != nil && (s.max == nil || bytes.Compare(after, s.max) <= 0) {
+		return seekBefore(c, after, true)
+	}
+	return seekBefore(c, s.max, s.exclusive)
+}
+
+// seekBefore moves `c` to the last key at or before `max` (or strictly
+// before, if `exclusive`), or to the last key if `max` is nil.
+func seekBefore(c *cursor, max []byte, exclusive bool) ([]byte, []byte) {
+	if max == nil {
+		return c.Last()
+	}
+	k, v := c.Seek(max)
+	if k == nil {
+		return c.Last()
+	}
+	if exclusive || !bytes.Equal(k, max) {
+		return c.Prev()
+	}
+	return k, v
+}
+
+// reverseMap applies `do` on the k/v pairs in span `s`, in descending
+// key order, starting before key `after` if set.
+func reverseMap(c *cursor, s span, after []byte,
+	do func(k, v []byte) error) error {
+
+	for k, v := s.last(c, after); k != nil && s.in(k); k, v = c.Prev() {
+		if err := do(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reversePage collects up to `limit` items from span `s`, in descending
+// key order, starting before key `after` if set.  Nested buckets are
+// skipped.
+func reversePage(c *cursor, s span, after []byte, limit int) (items []Item,
+	next []byte, err error) {
+
+	if limit <= 0 {
+		return nil, nil, fmt.Errorf("invalid page limit: %d", limit)
+	}
+	err = reverseMap(c, s, after, func(k, v []byte) error {
+		if v == nil {
+			return nil
+		}
+		if len(items) == limit {
+			next = clone(items[limit-1].Key)
+			return ErrStopIteration
+		}
+		items = append(items, Item{clone(k), clone(v)})
+		return nil
+	})
+	return items, next, stopped(err)
+}
+
+// successor returns the smallest key greater than every key with
+// prefix `pre`, or nil if there is none (i.e., `pre` is empty or
+// all 0xff bytes).
+func successor(pre []byte) []byte {
+	for i := len(pre) - 1; i >= 0; i-- {
+		if pre[i] != 0xff {
+			succ := clone(pre[:i+1])
+			succ[i]++
+			return succ
+		}
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package buckets_test
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// keyStrings returns the keys of `items` as strings.
+func keyStrings(items []buckets.Item) (keys []string) {
+	for _, item := range items {
+		keys = append(keys, string(item.Key))
+	}
+	return keys
+}
+
+// Ensure we can scan buckets, prefixes, and ranges in reverse.
+func TestReverse(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+	for _, k := range []string{
+		"a", "b1", "b2", "b3", "b\xff", "b\xff\xff", "c", "c1",
+	} {
+		things.Put([]byte(k), []byte(k))
+	}
+	things.New([]byte("b2x")) // nested buckets are skipped
+
+	items, err := things.ReverseItems()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	want := []string{"c1", "c", "b\xff\xff", "b\xff", "b3", "b2", "b1", "a"}
+	if got := keyStrings(items); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	keys, err := things.ReverseKeys()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(keys) != len(want) {
+		t.Errorf("got %d keys, want %d", len(keys), len(want))
+	}
+
+	prefixes := map[string][]string{
+		"b":        {"b\xff\xff", "b\xff", "b3", "b2", "b1"},
+		"b\xff":    {"b\xff\xff", "b\xff"},
+		"c":        {"c1", "c"},
+		"":         want,
+		"d":        nil,
+		"\xff\xff": nil,
+	}
+	for pre, want := range prefixes {
+		items, err := things.NewPrefixScanner([]byte(pre)).ReverseItems()
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if got := keyStrings(items); !reflect.DeepEqual(got, want) {
+			t.Errorf("prefix %q: got %q, want %q", pre, got, want)
+		}
+	}
+
+	ranges := []struct {
+		min, max string
+		want     []string
+	}{
+		{"b1", "b3", []string{"b3", "b2", "b1"}},
+		{"b15", "b25", []string{"b2"}},
+		{"a", "z", want},
+		{"x", "z", nil},
+	}
+	for _, r := range ranges {
+		rs := things.NewRangeScanner([]byte(r.min), []byte(r.max))
+		keys, err := rs.ReverseKeys()
+		if err != nil {
+			t.Error(err.Error())
+		}
+		var got []string
+		for _, k := range keys {
+			got = append(got, string(k))
+		}
+		if !reflect.DeepEqual(got, r.want) {
+			t.Errorf("range %q-%q: got %q, want %q", r.min, r.max, got, r.want)
+		}
+	}
+}
+
+// Ensure we can page through items in reverse.
+func TestReversePage(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+	for _, k := range []string{"a1", "a2", "a3", "a4", "a5", "b1", "b2"} {
+		things.Put([]byte(k), []byte(k))
+	}
+
+	tests := []struct {
+		name  string
+		pager pager
+		want  [][]string
+	}{
+		{
+			"bucket", things.ReversePage,
+			[][]string{{"b2", "b1", "a5"}, {"a4", "a3", "a2"}, {"a1"}},
+		},
+		{
+			"prefix", things.NewPrefixScanner([]byte("a")).ReversePage,
+			[][]string{{"a5", "a4", "a3"}, {"a2", "a1"}},
+		},
+		{
+			"range", things.NewRangeScanner([]byte("a2"), []byte("b1")).ReversePage,
+			[][]string{{"b1", "a5", "a4"}, {"a3", "a2"}},
+		},
+	}
+	for _, test := range tests {
+		if got := pages(t, test.pager, 3); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// Show that we can get the newest items in a time-keyed bucket.
+func ExampleRangeScanner_ReversePage() {
+	bx, _ := buckets.Open(tempfile())
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	logins, _ := bx.New([]byte("logins"))
+	for day := 1; day <= 9; day++ {
+		k := fmt.Sprintf("2016-01-%02d", day)
+		logins.Put([]byte(k), []byte(fmt.Sprintf("%d logins", day*10)))
+	}
+
+	// Get the latest three days within the first week.
+	week := logins.NewRangeScanner([]byte("2016-01-01"), []byte("2016-01-07"))
+	items, _, _ := week.ReversePage(3, nil)
+	for _, item := range items {
+		fmt.Printf("%s: %s\n", item.Key, item.Value)
+	}
+
+	// Output:
+	// 2016-01-07: 70 logins
+	// 2016-01-06: 60 logins
+	// 2016-01-05: 50 logins
+}
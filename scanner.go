@@ -1,9 +1,12 @@
 package buckets
 
+import "iter"
+
 // A Scanner implements methods for scanning a subset of keys
 // in a bucket and retrieving data from or about those keys.
 type Scanner interface {
-	// Map applies a func on each key/value pair scanned.
+	// Map applies a func on each key/value pair scanned, stopping
+	// if the func returns an error.
 	Map(func(k, v []byte) error) error
 	// Count returns a count of the scanned keys.
 	Count() (int, error)
@@ -15,4 +18,13 @@ type Scanner interface {
 	Items() ([]Item, error)
 	// ItemMapping returns a mapping of k/v pairs from scanned keys.
 	ItemMapping() (map[string][]byte, error)
+	// All returns an iterator over k/v pairs from scanned keys.
+	All() iter.Seq2[[]byte, []byte]
+	// AllErr returns an iterator over items from scanned keys, ending
+	// with any error from scanning them.
+	AllErr() iter.Seq2[Item, error]
+	// AllKeys returns an iterator over the scanned keys.
+	AllKeys() iter.Seq[[]byte]
+	// AllValues returns an iterator over values from scanned keys.
+	AllValues() iter.Seq[[]byte]
 }
@@ -0,0 +1,292 @@
+package buckets
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// ttlBucket holds the expiry times of keys put with PutWithTTL.  It
+// contains a bucket for each bucket with expiring keys (named by the
+// bucket's pathKey), which in turn contains a `keys` bucket mapping keys
+// to expiry times and a `times` bucket of expiry time + key pairs,
+// ordered by time for sweeping.
+var ttlBucket = []byte("\x00buckets/ttl")
+
+var (
+	ttlKeys  = []byte("keys")
+	ttlTimes = []byte("times")
+)
+
+// PutWithTTL inserts value `v` with key `k`, to expire once `ttl` has
+// elapsed.  Expired items are hidden from reads (Get, Items, the scanners,
+// &c.) and eventually deleted by Sweep.  Writing a key again with Put
+// (or any other method) clears its expiry time.
+func (bk *Bucket) PutWithTTL(k, v []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("invalid ttl: %v", ttl)
+	}
+	exp := encodeTime(bk.db.now().Add(ttl))
+	return bk.update(func(b *bolt.Bucket) error {
+		if err := bk.put(b, k, v); err != nil {
+			return err
+		}
+		keys, times, err := createExpiry(b.Tx(), bk.Path())
+		if err != nil {
+			return err
+		}
+		if err := keys.Put(k, exp); err != nil {
+			return err
+		}
+		return times.Put(timeKey(exp, k), []byte{})
+	})
+}
+
+// expiry returns the buckets holding expiry times for the bucket at
+// `path`, or nils if no keys in the bucket expire.
+func expiry(tx *bolt.Tx, path [][]byte) (keys, times *bolt.Bucket) {
+	root := tx.Bucket(ttlBucket)
+	if root == nil {
+		return nil, nil
+	}
+	b := root.Bucket(pathKey(path))
+	if b == nil {
+		return nil, nil
+	}
+	return b.Bucket(ttlKeys), b.Bucket(ttlTimes)
+}
+
+// createExpiry creates/opens the buckets holding expiry times for the
+// bucket at `path`.
+func createExpiry(tx *bolt.Tx, path [][]byte) (keys, times *bolt.Bucket,
+	err error) {
+
+	root, err := tx.CreateBucketIfNotExists(ttlBucket)
+	if err != nil {
+		return nil, nil, err
+	}
+	b, err := root.CreateBucketIfNotExists(pathKey(path))
+	if err != nil {
+		return nil, nil, err
+	}
+	if keys, err = b.CreateBucketIfNotExists(ttlKeys); err != nil {
+		return nil, nil, err
+	}
+	times, err = b.CreateBucketIfNotExists(ttlTimes)
+	return keys, times, err
+}
+
+// clearExpiry removes any expiry time for key `k` in the bucket at `path`.
+func clearExpiry(tx *bolt.Tx, path [][]byte, k []byte) error {
+	keys, times := expiry(tx, path)
+	if keys == nil {
+		return nil
+	}
+	exp := keys.Get(k)
+	if exp == nil {
+		return nil
+	}
+	if err := times.Delete(timeKey(exp, k)); err != nil {
+		return err
+	}
+	return keys.Delete(k)
+}
+
+// timeKey returns the key for `k` in a `times` bucket.
+func timeKey(exp, k []byte) []byte {
+	key := make([]byte, 0, len(exp)+len(k))
+	return append(append(key, exp...), k...)
+}
+
+// expired checks whether an encoded expiry time (if any) is at or
+// before `now`.
+func expired(exp []byte, now time.Time) bool {
+	return exp != nil && !decodeTime(exp).After(now)
+}
+
+// A cursor is a bolt cursor that skips expired keys.
+type cursor struct {
+	*bolt.Cursor
+	exp *bolt.Bucket // expiry times by key; nil if no keys expire
+	now time.Time
+}
+
+// cursor returns a cursor over b, bk's bolt bucket.
+func (bk *Bucket) cursor(b *bolt.Bucket) *cursor {
+	keys, _ := expiry(b.Tx(), bk.Path())
+	return &cursor{b.Cursor(), keys, bk.db.now()}
+}
+
+// First moves the cursor to the first unexpired item and returns
+// its key and value.
+func (c *cursor) First() ([]byte, []byte) {
+	k, v := c.Cursor.First()
+	return c.skip(k, v)
+}
+
+// Next moves the cursor to the next unexpired item and returns
+// its key and value.
+func (c *cursor) Next() ([]byte, []byte) {
+	k, v := c.Cursor.Next()
+	return c.skip(k, v)
+}
+
+// Seek moves the cursor to the first unexpired item with a key at or
+// after `seek` and returns its key and value.
+func (c *cursor) Seek(seek []byte) ([]byte, []byte) {
+	k, v := c.Cursor.Seek(seek)
+	return c.skip(k, v)
+}
+
+// Last moves the cursor to the last unexpired item and returns
+// its key and value.
+func (c *cursor) Last() ([]byte, []byte) {
+	k, v := c.Cursor.Last()
+	return c.skipBack(k, v)
+}
+
+// Prev moves the cursor to the previous unexpired item and returns
+// its key and value.
+func (c *cursor) Prev() ([]byte, []byte) {
+	k, v := c.Cursor.Prev()
+	return c.skipBack(k, v)
+}
+
+// skip moves the cursor forward past expired keys.
+func (c *cursor) skip(k, v []byte) ([]byte, []byte) {
+	for c.exp != nil && k != nil && expired(c.exp.Get(k), c.now) {
+		k, v = c.Cursor.Next()
+	}
+	return k, v
+}
+
+// skipBack moves the cursor backward past expired keys.
+func (c *cursor) skipBack(k, v []byte) ([]byte, []byte) {
+	for c.exp != nil && k != nil && expired(c.exp.Get(k), c.now) {
+		k, v = c.Cursor.Prev()
+	}
+	return k, v
+}
+
+// Sweep deletes expired items from all buckets, deleting at most `batch`
+// items per transaction.  It returns the number of items deleted.
+func (db *DB) Sweep(batch int) (n int, err error) {
+	if batch <= 0 {
+		return 0, fmt.Errorf("invalid batch size: %d", batch)
+	}
+	for {
+		deleted := 0
+		err = db.Update(func(tx *bolt.Tx) (err error) {
+			deleted, err = db.sweep(tx, batch)
+			return err
+		})
+		n += deleted
+		if err != nil || deleted < batch {
+			return n, err
+		}
+	}
+}
+
+// sweep deletes up to `limit` expired items within `tx`.
+func (db *DB) sweep(tx *bolt.Tx, limit int) (n int, err error) {
+	root := tx.Bucket(ttlBucket)
+	if root == nil {
+		return 0, nil
+	}
+	var paths [][]byte
+	root.ForEach(func(k, v []byte) error {
+		paths = append(paths, append([]byte{}, k...))
+		return nil
+	})
+	now := encodeTime(db.now())
+	for _, pk := range paths {
+		path := decodePath(pk)
+		bk := db.bucketAt(path)
+		b := bk.bucket(tx)
+		keys, times := expiry(tx, path)
+		if times == nil {
+			continue
+		}
+		c := times.Cursor()
+		for tk, _ := c.First(); tk != nil; tk, _ = c.First() {
+			if n == limit || bytes.Compare(tk[:8], now) > 0 {
+				break
+			}
+			k := append([]byte{}, tk[8:]...)
+			if b != nil {
+				err = bk.expire(b, k)
+			} else if err = times.Delete(tk); err == nil {
+				err = keys.Delete(k)
+			}
+			if err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// SweepOptions configure a Sweeper.
+type SweepOptions struct {
+	// Interval is the time between sweeps.  Defaults to a minute.
+	Interval time.Duration
+
+	// BatchSize is the maximum number of items deleted per transaction.
+	// Defaults to 1000.
+	BatchSize int
+
+	// OnError, if set, is called with any error from a sweep.
+	OnError func(err error)
+}
+
+// A Sweeper periodically deletes expired items in the background.
+type Sweeper struct {
+	db   *DB
+	opts SweepOptions
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// StartSweeper starts a goroutine that calls Sweep at regular intervals,
+// until the returned Sweeper is stopped.  Items are considered expired
+// according to the database's clock (see Options.Clock).
+func (db *DB) StartSweeper(opts SweepOptions) *Sweeper {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Minute
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	s := &Sweeper{db: db, opts: opts, stop: make(chan struct{})}
+	s.done.Add(1)
+	go s.run()
+	return s
+}
+
+// run sweeps at each tick until the sweeper is stopped.
+func (s *Sweeper) run() {
+	defer s.done.Done()
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			_, err := s.db.Sweep(s.opts.BatchSize)
+			if err != nil && s.opts.OnError != nil {
+				s.opts.OnError(err)
+			}
+		}
+	}
+}
+
+// Stop stops the sweeper, waiting for any sweep in progress to finish.
+func (s *Sweeper) Stop() {
+	close(s.stop)
+	s.done.Wait()
+}
@@ -0,0 +1,241 @@
+package buckets_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/joyrexus/buckets"
+)
+
+// A clock is a fake clock for testing expiry times.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// Now returns the clock's current time.
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by `d`.
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// openWithClock opens a buckets database using a fake clock.
+func openWithClock(t *testing.T) (*buckets.DB, *clock) {
+	c := &clock{now: time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)}
+	bx, err := buckets.OpenWithOptions(tempfile(), &buckets.Options{
+		Timeout: time.Second,
+		Clock:   c.Now,
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	return bx, c
+}
+
+// Ensure that expired items are hidden from reads.
+func TestPutWithTTL(t *testing.T) {
+	bx, clock := openWithClock(t)
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	cache, err := bx.New([]byte("cache"))
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	cache.Put([]byte("a/forever"), []byte("1"))
+	cache.PutWithTTL([]byte("a/minute"), []byte("2"), time.Minute)
+	cache.PutWithTTL([]byte("a/hour"), []byte("3"), time.Hour)
+
+	// Putting a key again without a ttl clears its expiry time.
+	cache.PutWithTTL([]byte("a/reset"), []byte("4"), time.Minute)
+	cache.Put([]byte("a/reset"), []byte("4"))
+
+	count := func() int {
+		items, err := cache.Items()
+		if err != nil {
+			t.Error(err.Error())
+		}
+		n, err := cache.NewPrefixScanner([]byte("a/")).Count()
+		if err != nil {
+			t.Error(err.Error())
+		}
+		if n != len(items) {
+			t.Errorf("prefix count %d differs from item count %d", n, len(items))
+		}
+		return len(items)
+	}
+
+	if n := count(); n != 4 {
+		t.Errorf("got %d items, want 4", n)
+	}
+
+	clock.Advance(time.Minute)
+
+	if n := count(); n != 3 {
+		t.Errorf("got %d items, want 3", n)
+	}
+	if _, err := cache.Get([]byte("a/minute")); !errors.Is(err, buckets.ErrKeyNotFound) {
+		t.Errorf("got error %v, want %v", err, buckets.ErrKeyNotFound)
+	}
+	if v, _ := cache.Get([]byte("a/hour")); string(v) != "3" {
+		t.Errorf("got %s, want 3", v)
+	}
+
+	// An expired key can be put again.
+	if err := cache.PutNX([]byte("a/minute"), []byte("5")); err != nil {
+		t.Error(err.Error())
+	}
+	if v, _ := cache.Get([]byte("a/minute")); string(v) != "5" {
+		t.Errorf("got %s, want 5", v)
+	}
+
+	clock.Advance(time.Hour)
+
+	items, _ := cache.RangeItems([]byte("a/"), []byte("a/z"))
+	if len(items) != 3 {
+		t.Errorf("got %d items, want 3", len(items))
+	}
+	items, _ = cache.NewPrefixScanner([]byte("a/")).ReverseItems()
+	if len(items) != 3 {
+		t.Errorf("got %d items in reverse, want 3", len(items))
+	}
+}
+
+// Ensure that watchers and the change log see expired values as
+// missing, as readers do.
+func TestExpiredChanges(t *testing.T) {
+	bx, clock := openWithLog(t, buckets.LogRetention{})
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	cache, _ := bx.New([]byte("cache"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := cache.Watch(ctx, nil, buckets.WatchOptions{})
+
+	cache.PutWithTTL([]byte("a"), []byte("1"), time.Minute)
+	next(t, w)
+	clock.Advance(time.Minute)
+	cache.Put([]byte("a"), []byte("2"))
+	if ev := next(t, w); ev.Old != nil {
+		t.Errorf("got old value %q of expired key, want nil", ev.Old)
+	}
+
+	entries, err := bx.ReadLog(1, 10)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(entries) != 1 || entries[0].Old != nil {
+		t.Errorf("got entries %v, want one with no old value", describe(entries))
+	}
+}
+
+// Ensure that watchers see swept keys deleted.
+func TestSweepEvents(t *testing.T) {
+	bx, clock := openWithClock(t)
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	cache, _ := bx.New([]byte("cache"))
+	cache.PutWithTTL([]byte("a"), []byte("1"), time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := cache.Watch(ctx, nil, buckets.WatchOptions{})
+
+	clock.Advance(time.Minute)
+	if n, err := bx.Sweep(10); err != nil || n != 1 {
+		t.Fatalf("got %d swept (%v), want 1", n, err)
+	}
+	ev := next(t, w)
+	if ev.Type != buckets.EventDelete || string(ev.Key) != "a" ||
+		string(ev.Old) != "1" {
+		t.Errorf("got %s event for %q (old %q), want delete of a (old 1)",
+			ev.Type, ev.Key, ev.Old)
+	}
+	none(t, w)
+}
+
+// Ensure that sweeping deletes expired items.
+func TestSweep(t *testing.T) {
+	bx, clock := openWithClock(t)
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	cache, _ := bx.New([]byte("cache"))
+	nested, _ := cache.New([]byte("nested"))
+
+	for _, bk := range []*buckets.Bucket{cache, nested} {
+		for _, k := range []string{"a", "b", "c", "d", "e"} {
+			bk.PutWithTTL([]byte(k), []byte(k), time.Minute)
+		}
+		bk.PutWithTTL([]byte("f"), []byte("f"), time.Hour)
+	}
+
+	// Nothing has expired yet.
+	if n, err := bx.Sweep(2); err != nil || n != 0 {
+		t.Errorf("got %d deleted (%v), want 0", n, err)
+	}
+
+	clock.Advance(time.Minute)
+
+	n, err := bx.Sweep(2)
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if n != 10 {
+		t.Errorf("got %d deleted, want 10", n)
+	}
+
+	for _, bk := range []*buckets.Bucket{cache, nested} {
+		items, _ := bk.Items()
+		if len(items) != 1 || string(items[0].Key) != "f" {
+			t.Errorf("%s: got %v, want just f", bk.Name, items)
+		}
+	}
+}
+
+// Ensure that the sweeper deletes expired items in the background.
+func TestSweeper(t *testing.T) {
+	bx, clock := openWithClock(t)
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	cache, _ := bx.New([]byte("cache"))
+	cache.PutWithTTL([]byte("a"), []byte("1"), time.Minute)
+
+	clock.Advance(time.Minute)
+
+	s := bx.StartSweeper(buckets.SweepOptions{
+		Interval: 10 * time.Millisecond,
+		OnError:  func(err error) { t.Error(err.Error()) },
+	})
+	defer s.Stop()
+
+	// Wait for a sweep to delete the item.  We check the bucket's key
+	// count, since reads hide the expired item whether swept or not.
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		info, err := cache.Info()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if info.KeyN == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("expired item was not swept")
+}
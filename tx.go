@@ -0,0 +1,108 @@
+package buckets
+
+import (
+	"fmt"
+
+	"github.com/boltdb/bolt"
+)
+
+// A Tx is a transaction spanning any number of buckets.
+//
+// Bucket handles obtained from a Tx are bound to it: their methods
+// (Put, Get, Delete, PrefixItems, RangeItems, the scanners, &c.) all run
+// within the one transaction, which commits or rolls back as a unit.
+// Bound handles are only valid until the transaction function returns.
+type Tx struct {
+	db *DB
+	tx *bolt.Tx
+}
+
+// Tx executes `fn` within a read-write transaction.  If `fn` returns
+// an error, the transaction is rolled back and the error is returned.
+// Otherwise, the transaction is committed.
+//
+// Note that calling methods on unbound bucket handles from within `fn`
+// will deadlock, since bolt allows only one writer at a time.  Use
+// the handles returned by the Tx instead.
+func (db *DB) Tx(fn func(tx *Tx) error) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		return fn(&Tx{db, tx})
+	})
+}
+
+// ReadTx executes `fn` within a read-only transaction, giving `fn`
+// a consistent view of the database across buckets.
+func (db *DB) ReadTx(fn func(tx *Tx) error) error {
+	return db.View(func(tx *bolt.Tx) error {
+		return fn(&Tx{db, tx})
+	})
+}
+
+// BeginTx starts a transaction, read-write if `writable` is set, for
+// when the transaction can't be confined to a function passed to Tx or
+// ReadTx (e.g., one spanning several commands in an interactive
+// session).  It must be ended with Commit or Rollback, and only used by
+// one goroutine at a time.  As with Tx, other writers wait until a
+// read-write transaction ends.
+func (db *DB) BeginTx(writable bool) (*Tx, error) {
+	tx, err := db.Begin(writable)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{db, tx}, nil
+}
+
+// Commit commits a transaction started with BeginTx.
+func (tx *Tx) Commit() error {
+	return tx.tx.Commit()
+}
+
+// Rollback rolls back a transaction started with BeginTx.
+func (tx *Tx) Rollback() error {
+	return tx.tx.Rollback()
+}
+
+// Writable reports whether the transaction can perform write operations.
+func (tx *Tx) Writable() bool {
+	return tx.tx.Writable()
+}
+
+// New creates/opens a named bucket within the transaction.  The returned
+// handle is bound to the transaction.
+func (tx *Tx) New(name []byte) (*Bucket, error) {
+	if _, err := tx.db.createBucket(tx.tx, tx.tx, [][]byte{name}); err != nil {
+		return nil, err
+	}
+	return &Bucket{db: tx.db, tx: tx.tx, Name: name}, nil
+}
+
+// Bucket returns a copy of the `bk` handle bound to the transaction.
+func (tx *Tx) Bucket(bk *Bucket) *Bucket {
+	bound := *bk
+	bound.tx = tx.tx
+	return &bound
+}
+
+// Buckets returns info about each top-level bucket, as in DB.Buckets,
+// with handles bound to the transaction.
+func (tx *Tx) Buckets() ([]BucketInfo, error) {
+	infos, err := tx.db.buckets(tx.tx)
+	for i := range infos {
+		infos[i].Bucket.tx = tx.tx
+	}
+	return infos, err
+}
+
+// BucketPath returns a handle bound to the transaction for the existing
+// nested bucket at `path`, as in DB.BucketPath.  It returns
+// ErrBucketNotFound if any bucket along the path does not exist.
+func (tx *Tx) BucketPath(path ...[]byte) (*Bucket, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("empty bucket path")
+	}
+	if err := checkPath(path); err != nil {
+		return nil, err
+	}
+	bk := tx.Bucket(tx.db.bucketAt(path))
+	return bk, bk.with(tx.tx, func(b *bolt.Bucket) error { return nil })
+}
@@ -0,0 +1,204 @@
+package buckets_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+// Ensure that writes to several buckets in a transaction commit together.
+func TestTx(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	todo, _ := bx.New([]byte("todo"))
+	done, _ := bx.New([]byte("done"))
+
+	k, v := []byte("wash"), []byte("laundry")
+	if err := todo.Put(k, v); err != nil {
+		t.Error(err.Error())
+	}
+
+	// Move the item from `todo` to `done`.
+	err := bx.Tx(func(tx *buckets.Tx) error {
+		from, to := tx.Bucket(todo), tx.Bucket(done)
+		v, err := from.Get(k)
+		if err != nil {
+			return err
+		}
+		if err := to.Put(k, v); err != nil {
+			return err
+		}
+		return from.Delete(k)
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+
+	if got, _ := todo.Get(k); got != nil {
+		t.Errorf("not expecting value for key %q in todo: got %q", k, got)
+	}
+	if got, _ := done.Get(k); !bytes.Equal(got, v) {
+		t.Errorf("got %q, want %q", got, v)
+	}
+}
+
+// Ensure that a transaction returning an error is rolled back.
+func TestTxRollback(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+
+	oops := errors.New("oops")
+	err := bx.Tx(func(tx *buckets.Tx) error {
+		bk := tx.Bucket(things)
+		if err := bk.Put([]byte("A"), []byte("alpha")); err != nil {
+			return err
+		}
+		// Buckets created in the transaction are rolled back too.
+		if _, err := tx.New([]byte("others")); err != nil {
+			return err
+		}
+		return oops
+	})
+	if err != oops {
+		t.Errorf("got error %v, want %v", err, oops)
+	}
+
+	items, err := things.Items()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(items) != 0 {
+		t.Errorf("got %d items, want none", len(items))
+	}
+
+	err = bx.ReadTx(func(tx *buckets.Tx) error {
+		others, err := tx.Bucket(things).Buckets()
+		if err != nil {
+			return err
+		}
+		if len(others) != 0 {
+			t.Errorf("got %d nested buckets, want none", len(others))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+}
+
+// Ensure that handles bound to a read-only transaction cannot write.
+func TestReadTx(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+	if err := things.Put([]byte("A"), []byte("alpha")); err != nil {
+		t.Error(err.Error())
+	}
+
+	err := bx.ReadTx(func(tx *buckets.Tx) error {
+		if tx.Writable() {
+			t.Error("read-only transaction should not be writable")
+		}
+		bk := tx.Bucket(things)
+		items, err := bk.NewPrefixScanner([]byte("A")).Items()
+		if err != nil {
+			return err
+		}
+		if len(items) != 1 {
+			t.Errorf("got %d items, want 1", len(items))
+		}
+		if err := bk.Put([]byte("B"), []byte("beta")); err == nil {
+			t.Error("expected error putting item in read-only transaction")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err.Error())
+	}
+}
+
+// Ensure that transactions can be begun and ended explicitly.
+func TestBeginTx(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, _ := bx.NewPath([]byte("things"), []byte("nested"))
+
+	tx, err := bx.BeginTx(true)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := tx.BucketPath([]byte("things"), []byte("nope")); !errors.Is(err, buckets.ErrBucketNotFound) {
+		t.Errorf("got error %v, want %v", err, buckets.ErrBucketNotFound)
+	}
+	bk, err := tx.BucketPath([]byte("things"), []byte("nested"))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	bk.Put([]byte("A"), []byte("alpha"))
+	if err := tx.Rollback(); err != nil {
+		t.Error(err.Error())
+	}
+	if _, err := things.Get([]byte("A")); !errors.Is(err, buckets.ErrKeyNotFound) {
+		t.Errorf("got error %v after rollback, want %v", err, buckets.ErrKeyNotFound)
+	}
+
+	tx, err = bx.BeginTx(true)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	tx.Bucket(things).Put([]byte("B"), []byte("beta"))
+	tx.New([]byte("others"))
+	infos, err := tx.Buckets()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	if len(infos) != 2 || string(infos[0].Bucket.Name) != "others" {
+		t.Errorf("got %d buckets, want others and things", len(infos))
+	}
+	if err := tx.Commit(); err != nil {
+		t.Error(err.Error())
+	}
+	if v, _ := things.Get([]byte("B")); string(v) != "beta" {
+		t.Errorf("got %q after commit, want beta", v)
+	}
+}
+
+// Show that we can read and write several items in one transaction.
+func ExampleDB_Tx() {
+	bx, _ := buckets.Open(tempfile())
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	accounts, _ := bx.New([]byte("accounts"))
+	accounts.Put([]byte("alice"), []byte("10"))
+	accounts.Put([]byte("bob"), []byte("0"))
+
+	// Swap balances atomically.
+	bx.Tx(func(tx *buckets.Tx) error {
+		bk := tx.Bucket(accounts)
+		a, _ := bk.Get([]byte("alice"))
+		b, _ := bk.Get([]byte("bob"))
+		if err := bk.Put([]byte("alice"), b); err != nil {
+			return err
+		}
+		return bk.Put([]byte("bob"), a)
+	})
+
+	items, _ := accounts.Items()
+	for _, item := range items {
+		fmt.Printf("%s -> %s\n", item.Key, item.Value)
+	}
+
+	// Output:
+	// alice -> 0
+	// bob -> 10
+}
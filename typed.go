@@ -0,0 +1,236 @@
+package buckets
+
+import (
+	"fmt"
+	"iter"
+)
+
+// A TypedBucket stores keys of type K and values of type V in a bucket,
+// converting them to and from bytes with a pair of codecs.
+//
+// Since keys are scanned in the byte order of their encodings, prefix
+// and range scans only make sense with a key codec that preserves the
+// order of K (e.g., Raw for strings).
+type TypedBucket[K, V any] struct {
+	Bucket     *Bucket
+	KeyCodec   Codec
+	ValueCodec Codec
+}
+
+// A TypedItem holds a key/value pair of a TypedBucket.
+type TypedItem[K, V any] struct {
+	Key   K
+	Value V
+}
+
+// NewTypedBucket returns a typed view of bucket `bk`, encoding keys with
+// `keys` and values with `values`:
+//
+//	todos := buckets.NewTypedBucket[string, Todo](bk, buckets.Raw, buckets.JSON)
+func NewTypedBucket[K, V any](bk *Bucket, keys, values Codec) *TypedBucket[K, V] {
+	return &TypedBucket[K, V]{Bucket: bk, KeyCodec: keys, ValueCodec: values}
+}
+
+// Put saves the value `v` under key `k`.
+func (tb *TypedBucket[K, V]) Put(k K, v V) error {
+	key, err := tb.key(k)
+	if err != nil {
+		return err
+	}
+	value, err := tb.ValueCodec.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding value: %w", err)
+	}
+	return tb.Bucket.Put(key, value)
+}
+
+// Get retrieves the value for key `k`, returning ErrKeyNotFound if
+// there is none.
+func (tb *TypedBucket[K, V]) Get(k K) (v V, err error) {
+	key, err := tb.key(k)
+	if err != nil {
+		return v, err
+	}
+	value, err := tb.Bucket.Get(key)
+	if err != nil {
+		return v, err
+	}
+	if err := tb.ValueCodec.Unmarshal(value, &v); err != nil {
+		return v, fmt.Errorf("decoding value for key %q: %w", key, err)
+	}
+	return v, nil
+}
+
+// Delete removes key `k`.
+func (tb *TypedBucket[K, V]) Delete(k K) error {
+	key, err := tb.key(k)
+	if err != nil {
+		return err
+	}
+	return tb.Bucket.Delete(key)
+}
+
+// Items returns a slice of the bucket's key/value pairs.
+func (tb *TypedBucket[K, V]) Items() ([]TypedItem[K, V], error) {
+	return tb.items(tb.Bucket.Map)
+}
+
+// PrefixItems returns a slice of key/value pairs for keys whose
+// encoding has the prefix `pre`.
+func (tb *TypedBucket[K, V]) PrefixItems(pre []byte) ([]TypedItem[K, V], error) {
+	return tb.items(tb.Bucket.NewPrefixScanner(pre).Map)
+}
+
+// RangeItems returns a slice of key/value pairs for keys within the
+// (inclusive) range from `min` to `max`.
+func (tb *TypedBucket[K, V]) RangeItems(min, max K) ([]TypedItem[K, V], error) {
+	rs, err := tb.rangeScanner(min, max)
+	if err != nil {
+		return nil, err
+	}
+	return tb.items(rs.Map)
+}
+
+// Map applies `do` on each key/value pair.  Errors returned by `do` are
+// handled as in Bucket.Map, and Map stops with an error if a key or
+// value cannot be decoded.
+func (tb *TypedBucket[K, V]) Map(do func(k K, v V) error) error {
+	return stopped(tb.mapOf(tb.Bucket.Map, do))
+}
+
+// All returns an iterator over the bucket's key/value pairs.
+// The sequence ends early if a key or value cannot be decoded: use
+// AllErr to detect such errors.  See Bucket.All.
+func (tb *TypedBucket[K, V]) All() iter.Seq2[K, V] {
+	return tb.seq(tb.Bucket.Map)
+}
+
+// AllErr returns an iterator over the bucket's items, each with a nil
+// error, ending with the error if the bucket cannot be read or an item
+// cannot be decoded.  See Bucket.AllErr.
+func (tb *TypedBucket[K, V]) AllErr() iter.Seq2[TypedItem[K, V], error] {
+	return tb.seqErr(tb.Bucket.Map)
+}
+
+// AllPrefix returns an iterator over the key/value pairs for keys whose
+// encoding has the prefix `pre`.  See All.
+func (tb *TypedBucket[K, V]) AllPrefix(pre []byte) iter.Seq2[K, V] {
+	return tb.seq(tb.Bucket.NewPrefixScanner(pre).Map)
+}
+
+// AllPrefixErr is like AllPrefix, but reports errors.  See AllErr.
+func (tb *TypedBucket[K, V]) AllPrefixErr(pre []byte) iter.Seq2[TypedItem[K, V],
+	error] {
+
+	return tb.seqErr(tb.Bucket.NewPrefixScanner(pre).Map)
+}
+
+// AllRange returns an iterator over the key/value pairs for keys within
+// the (inclusive) range from `min` to `max`.  The sequence is empty if
+// `min` or `max` cannot be encoded: use AllRangeErr to detect such
+// errors.  See All.
+func (tb *TypedBucket[K, V]) AllRange(min, max K) iter.Seq2[K, V] {
+	rs, err := tb.rangeScanner(min, max)
+	if err != nil {
+		return func(yield func(K, V) bool) {}
+	}
+	return tb.seq(rs.Map)
+}
+
+// AllRangeErr is like AllRange, but reports errors, including those
+// encoding `min` and `max`.  See AllErr.
+func (tb *TypedBucket[K, V]) AllRangeErr(min, max K) iter.Seq2[TypedItem[K, V],
+	error] {
+
+	rs, err := tb.rangeScanner(min, max)
+	if err != nil {
+		return func(yield func(TypedItem[K, V], error) bool) {
+			yield(TypedItem[K, V]{}, err)
+		}
+	}
+	return tb.seqErr(rs.Map)
+}
+
+// key returns the encoding of key `k`.
+func (tb *TypedBucket[K, V]) key(k K) ([]byte, error) {
+	key, err := tb.KeyCodec.Marshal(k)
+	if err != nil {
+		return nil, fmt.Errorf("encoding key: %w", err)
+	}
+	return key, nil
+}
+
+// rangeScanner returns a scanner over the keys from `min` to `max`.
+func (tb *TypedBucket[K, V]) rangeScanner(min, max K) (*RangeScanner, error) {
+	lo, err := tb.key(min)
+	if err != nil {
+		return nil, err
+	}
+	hi, err := tb.key(max)
+	if err != nil {
+		return nil, err
+	}
+	return tb.Bucket.NewRangeScanner(lo, hi), nil
+}
+
+// mapOf decodes the k/v pairs of `m` and applies `do` on them, skipping
+// nested buckets.  Errors are returned as is, including ErrStopIteration.
+func (tb *TypedBucket[K, V]) mapOf(m mapper, do func(k K, v V) error) error {
+	return m(func(key, value []byte) error {
+		if value == nil {
+			return nil
+		}
+		var k K
+		var v V
+		if err := tb.KeyCodec.Unmarshal(key, &k); err != nil {
+			return fmt.Errorf("decoding key %q: %w", key, err)
+		}
+		if err := tb.ValueCodec.Unmarshal(value, &v); err != nil {
+			return fmt.Errorf("decoding value for key %q: %w", key, err)
+		}
+		return do(k, v)
+	})
+}
+
+// items collects the decoded k/v pairs of `m`.
+func (tb *TypedBucket[K, V]) items(m mapper) (items []TypedItem[K, V],
+	err error) {
+
+	err = tb.mapOf(m, func(k K, v V) error {
+		items = append(items, TypedItem[K, V]{k, v})
+		return nil
+	})
+	return items, err
+}
+
+// seqErr returns an iterator over the decoded items of `m`, ending with
+// the error from `m` or from decoding, if any.
+func (tb *TypedBucket[K, V]) seqErr(m mapper) iter.Seq2[TypedItem[K, V],
+	error] {
+
+	return func(yield func(TypedItem[K, V], error) bool) {
+		done := false
+		err := tb.mapOf(m, func(k K, v V) error {
+			if !yield(TypedItem[K, V]{k, v}, nil) {
+				done = true
+				return ErrStopIteration
+			}
+			return nil
+		})
+		if err != nil && !done {
+			yield(TypedItem[K, V]{}, err)
+		}
+	}
+}
+
+// seq returns an iterator over the decoded k/v pairs of `m`.
+func (tb *TypedBucket[K, V]) seq(m mapper) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		tb.mapOf(m, func(k K, v V) error {
+			if !yield(k, v) {
+				return ErrStopIteration
+			}
+			return nil
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package buckets_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/joyrexus/buckets"
+)
+
+type todo struct {
+	Task string
+	Done bool
+}
+
+// Ensure we can put, get, and scan typed items with each codec.
+func TestTypedBucket(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	codecs := map[string]buckets.Codec{
+		"json": buckets.JSON,
+		"gob":  buckets.Gob,
+	}
+	for name, codec := range codecs {
+		bk, _ := bx.New([]byte(name))
+		todos := buckets.NewTypedBucket[string, todo](bk, buckets.Raw, codec)
+
+		want := map[string]todo{
+			"2016-01-01/a": {"milk cows", true},
+			"2016-01-01/b": {"fold laundry", false},
+			"2016-01-02/a": {"flip burgers", false},
+			"2016-01-03/a": {"join army", true},
+		}
+		for k, v := range want {
+			if err := todos.Put(k, v); err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+		}
+
+		got, err := todos.Get("2016-01-01/b")
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if got != want["2016-01-01/b"] {
+			t.Errorf("%s: got %v, want %v", name, got, want["2016-01-01/b"])
+		}
+
+		items, err := todos.Items()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if len(items) != len(want) {
+			t.Errorf("%s: got %d items, want %d", name, len(items), len(want))
+		}
+		for _, item := range items {
+			if item.Value != want[item.Key] {
+				t.Errorf("%s: got %v, want %v", name, item.Value, want[item.Key])
+			}
+		}
+
+		jan1, err := todos.PrefixItems([]byte("2016-01-01"))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if len(jan1) != 2 {
+			t.Errorf("%s: got %d items with prefix, want 2", name, len(jan1))
+		}
+
+		var keys []string
+		for k := range todos.AllRange("2016-01-02", "2016-01-03/z") {
+			keys = append(keys, k)
+		}
+		wantKeys := []string{"2016-01-02/a", "2016-01-03/a"}
+		if !reflect.DeepEqual(keys, wantKeys) {
+			t.Errorf("%s: got %v, want %v", name, keys, wantKeys)
+		}
+
+		if err := todos.Delete("2016-01-03/a"); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		if _, err := todos.Get("2016-01-03/a"); !errors.Is(err, buckets.ErrKeyNotFound) {
+			t.Errorf("%s: got error %v, want %v", name, err, buckets.ErrKeyNotFound)
+		}
+	}
+}
+
+// Ensure that values which cannot be decoded are reported.
+func TestTypedBucketDecodeError(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	bk, _ := bx.New([]byte("todos"))
+	todos := buckets.NewTypedBucket[string, todo](bk, buckets.Raw, buckets.JSON)
+
+	todos.Put("a", todo{Task: "milk cows"})
+	bk.Put([]byte("b"), []byte("not json"))
+
+	if _, err := todos.Get("b"); err == nil {
+		t.Error("expected error decoding value")
+	}
+	if _, err := todos.Items(); err == nil {
+		t.Error("expected error decoding items")
+	}
+
+	// The iterator stops at the value it cannot decode.
+	var keys []string
+	for k := range todos.All() {
+		keys = append(keys, k)
+	}
+	if !reflect.DeepEqual(keys, []string{"a"}) {
+		t.Errorf("got %v, want [a]", keys)
+	}
+
+	// AllErr reports the error.
+	keys = nil
+	var errs []error
+	for item, err := range todos.AllErr() {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		keys = append(keys, item.Key)
+	}
+	if !reflect.DeepEqual(keys, []string{"a"}) || len(errs) != 1 {
+		t.Errorf("got keys %v and errors %v, want [a] and a decoding error",
+			keys, errs)
+	}
+
+	// The raw codec only handles byte slices and strings.
+	ints := buckets.NewTypedBucket[int, string](bk, buckets.Raw, buckets.Raw)
+	if err := ints.Put(1, "one"); err == nil {
+		t.Error("expected error encoding int key with raw codec")
+	}
+	n := 0
+	for _, err := range ints.AllRangeErr(1, 2) {
+		if err == nil {
+			t.Error("expected error encoding range with raw codec")
+		}
+		n++
+	}
+	if n != 1 {
+		t.Errorf("got %d items, want just the error", n)
+	}
+}
+
+// Show that we can store typed values without marshaling them ourselves.
+func ExampleTypedBucket() {
+	bx, _ := buckets.Open(tempfile())
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	type Todo struct {
+		Task string
+		Day  string
+	}
+
+	bk, _ := bx.New([]byte("todos"))
+	todos := buckets.NewTypedBucket[string, Todo](bk, buckets.Raw, buckets.JSON)
+
+	todos.Put("/mon", Todo{Task: "milk cows", Day: "mon"})
+	todos.Put("/tue", Todo{Task: "fold laundry", Day: "tue"})
+
+	mon, _ := todos.Get("/mon")
+	fmt.Println(mon.Task)
+
+	for day, todo := range todos.All() {
+		fmt.Printf("%s: %s\n", day, todo.Task)
+	}
+
+	// Output:
+	// milk cows
+	// /mon: milk cows
+	// /tue: fold laundry
+}
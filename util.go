@@ -1,8 +1,82 @@
 package buckets
 
-import "bytes"
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
 
-// isBefore checks whether `key` comes before `max`.
+// isBefore checks whether `key` comes before (or is equal to) `max`.
+// A nil `max` is unbounded: every key comes before it.
 func isBefore(key, max []byte) bool {
-	return key != nil && bytes.Compare(key, max) <= 0
+	return key != nil && (max == nil || bytes.Compare(key, max) <= 0)
+}
+
+// clone returns a copy of `b`, which may be a slice of bolt's
+// memory-mapped data file, valid only during a transaction.
+func clone(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+// stopped returns nil if `err` is (or wraps) ErrStopIteration, `err`
+// otherwise.
+func stopped(err error) error {
+	if errors.Is(err, ErrStopIteration) {
+		return nil
+	}
+	return err
+}
+
+// matches checks whether the current value `cur` of a key (nil if the
+// key does not exist) matches the `expected` value, where a nil
+// `expected` value stands for a missing key.
+func matches(cur, expected []byte) bool {
+	if cur == nil || expected == nil {
+		return cur == nil && expected == nil
+	}
+	return bytes.Equal(cur, expected)
+}
+
+// pathKey encodes a bucket path as a single key.  Each name is
+// length-prefixed, so the key for a bucket is a prefix of the keys
+// for the buckets nested within it (and of no others).
+func pathKey(path [][]byte) []byte {
+	var key []byte
+	for _, name := range path {
+		key = binary.AppendUvarint(key, uint64(len(name)))
+		key = append(key, name...)
+	}
+	return key
+}
+
+// decodePath decodes a bucket path encoded with pathKey.
+func decodePath(key []byte) (path [][]byte) {
+	for len(key) > 0 {
+		n, size := binary.Uvarint(key)
+		if size <= 0 || uint64(len(key)-size) < n {
+			return path
+		}
+		key = key[size:]
+		path = append(path, key[:n:n])
+		key = key[n:]
+	}
+	return path
+}
+
+// samePath checks whether two bucket paths are equal.
+func samePath(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
 }
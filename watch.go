@@ -0,0 +1,206 @@
+package buckets
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/boltdb/bolt"
+)
+
+// An EventType identifies the kind of change reported by an Event.
+type EventType int
+
+const (
+	// EventPut reports that a key was put (created or updated).
+	EventPut EventType = iota + 1
+
+	// EventDelete reports that a key was deleted, including when an
+	// expired key is swept.
+	EventDelete
+)
+
+// String returns "put" or "delete".
+func (t EventType) String() string {
+	switch t {
+	case EventPut:
+		return "put"
+	case EventDelete:
+		return "delete"
+	}
+	return "unknown"
+}
+
+// An Event reports a change to a key, made in a committed transaction.
+type Event struct {
+	Type EventType
+	Path [][]byte // path of the bucket containing the key
+	Key  []byte
+	Old  []byte // the previous value; nil if the key did not exist
+	New  []byte // the new value; nil if the key was deleted
+}
+
+// WatchOptions configure a Watcher.
+type WatchOptions struct {
+	// Buffer is the number of events that can be queued for delivery.
+	// Defaults to 64.
+	Buffer int
+
+	// Block, if set, makes writers wait for room in the buffer when
+	// it is full.  By default, events that don't fit are dropped (see
+	// Watcher.Dropped), so that a slow watcher can't hold up writers.
+	Block bool
+}
+
+// A Watcher delivers events for the changes to a bucket (or to all
+// buckets) on its channel, C.
+//
+// Events are sent after the writing transaction has committed, from
+// the goroutine that committed it, in the order of the writes within
+// the transaction.  (Events from transactions committed one right
+// after the other may be interleaved.)  With WatchOptions.Block set,
+// that goroutine waits until the event is received.
+//
+// The byte slices of an event are shared by all watchers receiving it,
+// so they must not be modified.
+type Watcher struct {
+	C <-chan Event
+
+	c       chan Event
+	path    [][]byte // nil to watch all buckets
+	prefix  []byte
+	block   bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mu      sync.Mutex // guards sends on c and closing it
+	closed  bool
+	dropped atomic.Uint64
+}
+
+// Watch returns a Watcher receiving events for the keys with prefix
+// `pre` in the bucket (not including nested buckets), until `ctx` is
+// done or the watcher is stopped.
+func (bk *Bucket) Watch(ctx context.Context, pre []byte,
+	opts WatchOptions) *Watcher {
+
+	return bk.db.watch(ctx, bk.Path(), clone(pre), opts)
+}
+
+// Watch returns a Watcher receiving events for the keys of every
+// bucket, until `ctx` is done or the watcher is stopped.
+func (db *DB) Watch(ctx context.Context, opts WatchOptions) *Watcher {
+	return db.watch(ctx, nil, nil, opts)
+}
+
+// watch registers a new watcher for the bucket at `path`.
+func (db *DB) watch(ctx context.Context, path [][]byte, pre []byte,
+	opts WatchOptions) *Watcher {
+
+	if opts.Buffer <= 0 {
+		opts.Buffer = 64
+	}
+	c := make(chan Event, opts.Buffer)
+	w := &Watcher{
+		C:      c,
+		c:      c,
+		path:   path,
+		prefix: pre,
+		block:  opts.Block,
+	}
+	w.ctx, w.cancel = context.WithCancel(ctx)
+
+	db.mu.Lock()
+	if db.watchers == nil {
+		db.watchers = make(map[*Watcher]bool)
+	}
+	db.watchers[w] = true
+	db.mu.Unlock()
+
+	go func() {
+		<-w.ctx.Done()
+		db.mu.Lock()
+		delete(db.watchers, w)
+		db.mu.Unlock()
+		w.mu.Lock()
+		w.closed = true
+		close(w.c)
+		w.mu.Unlock()
+	}()
+	return w
+}
+
+// Stop stops the watcher.  Its channel is closed once any pending
+// send is abandoned; events already buffered can still be received.
+func (w *Watcher) Stop() {
+	w.cancel()
+}
+
+// Dropped returns the number of events dropped because the watcher's
+// buffer was full.
+func (w *Watcher) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// matches checks whether the watcher wants event `ev`.
+func (w *Watcher) matches(ev Event) bool {
+	if w.path == nil {
+		return true
+	}
+	return samePath(w.path, ev.Path) && bytes.HasPrefix(ev.Key, w.prefix)
+}
+
+// send delivers event `ev`, or drops it if the buffer is full and the
+// watcher doesn't block.
+func (w *Watcher) send(ev Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	if w.block {
+		select {
+		case w.c <- ev:
+		case <-w.ctx.Done():
+		}
+		return
+	}
+	select {
+	case w.c <- ev:
+	default:
+		w.dropped.Add(1)
+	}
+}
+
+// watching checks whether any watchers are registered.
+func (db *DB) watching() bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return len(db.watchers) > 0
+}
+
+// notify arranges for the change of key `k` in the bucket at `path`,
+// from `old` to `v`, to be sent to the interested watchers once `tx`
+// commits.
+func (db *DB) notify(tx *bolt.Tx, path [][]byte, k, old, v []byte) {
+	if old == nil && v == nil {
+		return
+	}
+	ev := Event{EventPut, path, clone(k), old, clone(v)}
+	if v == nil {
+		ev.Type = EventDelete
+	}
+	tx.OnCommit(func() {
+		db.mu.Lock()
+		var ws []*Watcher
+		for w := range db.watchers {
+			if w.matches(ev) {
+				ws = append(ws, w)
+			}
+		}
+		db.mu.Unlock()
+		for _, w := range ws {
+			w.send(ev)
+		}
+	})
+}
@@ -0,0 +1,182 @@
+package buckets_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/joyrexus/buckets"
+)
+
+// next receives the next event from watcher `w`, failing if there is
+// none in time.
+func next(t *testing.T, w *buckets.Watcher) buckets.Event {
+	t.Helper()
+	select {
+	case ev, ok := <-w.C:
+		if !ok {
+			t.Fatal("watcher channel closed")
+		}
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	return buckets.Event{}
+}
+
+// none checks that watcher `w` has no pending events.
+func none(t *testing.T, w *buckets.Watcher) {
+	t.Helper()
+	select {
+	case ev := <-w.C:
+		t.Errorf("unexpected %s event for key %s", ev.Type, ev.Key)
+	default:
+	}
+}
+
+// Ensure that watchers receive committed puts and deletes.
+func TestWatch(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := things.Watch(ctx, []byte("a"), buckets.WatchOptions{})
+
+	things.Put([]byte("a1"), []byte("one"))
+	things.Put([]byte("b1"), []byte("skipped"))
+	things.Put([]byte("a1"), []byte("uno"))
+	things.Delete([]byte("a1"))
+	things.Delete([]byte("a2")) // not there, so no event
+
+	want := []buckets.Event{
+		{Type: buckets.EventPut, Key: []byte("a1"), New: []byte("one")},
+		{Type: buckets.EventPut, Key: []byte("a1"), Old: []byte("one"),
+			New: []byte("uno")},
+		{Type: buckets.EventDelete, Key: []byte("a1"), Old: []byte("uno")},
+	}
+	for _, we := range want {
+		ev := next(t, w)
+		if ev.Type != we.Type || !bytes.Equal(ev.Key, we.Key) ||
+			!bytes.Equal(ev.Old, we.Old) || !bytes.Equal(ev.New, we.New) {
+			t.Errorf("got %s %s %q -> %q, want %s %s %q -> %q",
+				ev.Type, ev.Key, ev.Old, ev.New,
+				we.Type, we.Key, we.Old, we.New)
+		}
+		if got := string(bytes.Join(ev.Path, []byte("/"))); got != "things" {
+			t.Errorf("got path %s, want things", got)
+		}
+	}
+	none(t, w)
+
+	// Changes rolled back are not reported.
+	bx.Tx(func(tx *buckets.Tx) error {
+		tx.Bucket(things).Put([]byte("a3"), []byte("three"))
+		return errors.New("oops")
+	})
+	none(t, w)
+
+	// Cancelling the context closes the channel.
+	cancel()
+	select {
+	case _, ok := <-w.C:
+		if ok {
+			t.Error("expected channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Error("timed out waiting for channel to close")
+	}
+}
+
+// Ensure that a database watcher receives events from every bucket.
+func TestWatchDB(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	w := bx.Watch(context.Background(), buckets.WatchOptions{})
+	defer w.Stop()
+
+	users, _ := bx.New([]byte("users"))
+	alice, _ := users.New([]byte("alice"))
+	users.Put([]byte("count"), []byte("1"))
+	alice.Put([]byte("email"), []byte("alice@example.com"))
+
+	for _, want := range []string{"users:count", "users/alice:email"} {
+		ev := next(t, w)
+		got := fmt.Sprintf("%s:%s", bytes.Join(ev.Path, []byte("/")), ev.Key)
+		if got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
+
+// Ensure that events are dropped when a watcher's buffer is full,
+// unless it blocks.
+func TestWatchBuffer(t *testing.T) {
+	bx := NewTestDB()
+	defer bx.Close()
+
+	things, _ := bx.New([]byte("things"))
+	ctx := context.Background()
+
+	dropper := things.Watch(ctx, nil, buckets.WatchOptions{Buffer: 1})
+	defer dropper.Stop()
+	blocker := things.Watch(ctx, nil, buckets.WatchOptions{
+		Buffer: 1,
+		Block:  true,
+	})
+	defer blocker.Stop()
+
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 3; i++ {
+			things.Put([]byte{'a' + byte(i)}, []byte("x"))
+		}
+		close(done)
+	}()
+
+	for _, want := range []string{"a", "b", "c"} {
+		if ev := next(t, blocker); string(ev.Key) != want {
+			t.Errorf("got key %s, want %s", ev.Key, want)
+		}
+	}
+	<-done
+
+	if ev := next(t, dropper); string(ev.Key) != "a" {
+		t.Errorf("got key %s, want a", ev.Key)
+	}
+	none(t, dropper)
+	if n := dropper.Dropped(); n != 2 {
+		t.Errorf("got %d dropped events, want 2", n)
+	}
+}
+
+// Show that we can watch a bucket for changes.
+func ExampleBucket_Watch() {
+	bx, _ := buckets.Open(tempfile())
+	defer os.Remove(bx.Path())
+	defer bx.Close()
+
+	todos, _ := bx.New([]byte("todos"))
+	w := todos.Watch(context.Background(), nil, buckets.WatchOptions{})
+	defer w.Stop()
+
+	todos.Put([]byte("mon"), []byte("milk cows"))
+	todos.Put([]byte("mon"), []byte("milk goats"))
+	todos.Delete([]byte("mon"))
+
+	for i := 0; i < 3; i++ {
+		ev := <-w.C
+		fmt.Printf("%s %s: %q -> %q\n", ev.Type, ev.Key, ev.Old, ev.New)
+	}
+
+	// Output:
+	// put mon: "" -> "milk cows"
+	// put mon: "milk cows" -> "milk goats"
+	// delete mon: "milk goats" -> ""
+}